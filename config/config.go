@@ -2,12 +2,36 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"log/slog"
+	"time"
 )
 
 var (
 	Help = flag.Bool("help", false, "Show help message")
 	Dir  = flag.String("dir", "Logger", "Path to the data directory")
 
+	LoyaltyEarnRate   = flag.Float64("loyalty-earn-rate", 1, "Loyalty points earned per unit of currency paid")
+	LoyaltyPointValue = flag.Float64("loyalty-point-value", 0.01, "Discount in currency units given per redeemed loyalty point")
+
+	PreorderLeadTime  = flag.Duration("preorder-lead-time", 15*time.Minute, "How long before pickup a scheduled order is sent to the queue")
+	SchedulerInterval = flag.Duration("scheduler-interval", time.Minute, "How often scheduled orders are checked")
+
+	Timezone = flag.String("timezone", "Asia/Almaty", "Business timezone (IANA name) used for dates in responses, date filters and reports")
+
+	IdempotencyKeyTTL = flag.Duration("idempotency-key-ttl", 24*time.Hour, "How long responses to requests with an Idempotency-Key are kept for replay")
+
 	Logger *slog.Logger
+
+	// BusinessLocation is the loaded Timezone, set on start.
+	BusinessLocation = time.UTC
 )
+
+// LoadLocation loads a timezone by IANA name, e.g. Asia/Almaty. The name is
+// also handed to PostgreSQL, so Go's "Local" is not accepted.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
+}
@@ -1,14 +1,55 @@
 -- Создание типа для статуса заказа
-CREATE TYPE order_status_enum AS ENUM('active','closed');
+CREATE TYPE order_status_enum AS ENUM('scheduled','pending','preparing','ready','picked_up','closed','cancelled');
 
--- Таблица orders с полем customer_name вместо customer_id
+-- Тип способа оплаты
+CREATE TYPE payment_method_enum AS ENUM('cash','card','mobile','gift_card');
+
+-- Тип размера позиции
+CREATE TYPE item_size_enum AS ENUM('small','medium','large');
+
+-- Причина возврата
+CREATE TYPE refund_reason_enum AS ENUM('customer_complaint','wrong_item','quality_issue','not_made','other');
+
+-- Тип скидки и область ее действия
+CREATE TYPE discount_type_enum AS ENUM('percentage','fixed');
+CREATE TYPE promotion_scope_enum AS ENUM('order','tag','item');
+
+-- Тип операции по подарочной карте
+CREATE TYPE gift_card_transaction_enum AS ENUM('issue','top_up','redeem');
+
+-- Тип записи в журнале баллов лояльности
+CREATE TYPE loyalty_entry_enum AS ENUM('earn','redeem','reverse','adjustment');
+
+-- Тип движения по складу
+CREATE TYPE inventory_transaction_enum AS ENUM('adjustment','consumption','return');
+
+-- Таблица customers: профили постоянных клиентов
+CREATE TABLE customers(
+    customer_id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    phone VARCHAR(32) UNIQUE,
+    email VARCHAR(255) UNIQUE,
+    preferences JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Таблица orders: customer_id ссылается на профиль, customer_name хранит имя
+-- на момент заказа и заполняется и для гостей без профиля
 CREATE TABLE orders(
     order_id SERIAL PRIMARY KEY, 
+    customer_id INT REFERENCES customers(customer_id) ON DELETE SET NULL,
     customer_name VARCHAR(255) NOT NULL,  -- добавлено поле для имени клиента
+    loyalty_id VARCHAR(64),  -- телефон или номер карты лояльности
     order_date TIMESTAMPTZ DEFAULT NOW(),
     status order_status_enum NOT NULL,
-    total_amount DECIMAL(10,2) NOT NULL CHECK(total_amount>0),
-    special_instructions JSONB
+    subtotal DECIMAL(10,2) NOT NULL CHECK(subtotal>=0),  -- сумма позиций до скидок
+    discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK(discount_amount>=0),
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK(tax_amount>=0),  -- включенный и начисленный налог
+    tip_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK(tip_amount>=0),
+    total_amount DECIMAL(10,2) NOT NULL CHECK(total_amount>=0),
+    special_instructions JSONB,
+    pickup_at TIMESTAMPTZ,  -- время выдачи предзаказа
+    fulfillment_issue VARCHAR(50)  -- почему предзаказ нельзя выполнить
 );
 
 -- Таблица menu_items
@@ -23,11 +64,127 @@ CREATE TABLE menu_items(
 -- Таблица order_items
 CREATE TABLE order_items(
     order_item_id SERIAL PRIMARY KEY,
-    menu_item_id INT REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    menu_item_id INT REFERENCES menu_items(menu_item_id) ON DELETE SET NULL,  -- NULL, если позицию убрали из меню
+    item_name VARCHAR(100) NOT NULL,  -- название позиции на момент заказа
     order_id INT REFERENCES orders(order_id) ON DELETE CASCADE,
     customizations JSONB,
     price_at_order_time DECIMAL(10,2) NOT NULL CHECK(price_at_order_time>0),
-    quantity INT NOT NULL CHECK (quantity >0)
+    quantity INT NOT NULL CHECK (quantity >0),
+    size item_size_enum NOT NULL DEFAULT 'medium'
+);
+
+-- Таблица gift_cards: подарочные карты, баланс - непогашенное обязательство
+CREATE TABLE gift_cards(
+    gift_card_id SERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL UNIQUE,
+    balance DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK(balance>=0),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Таблица payments: один заказ может быть оплачен несколькими способами
+CREATE TABLE payments(
+    payment_id SERIAL PRIMARY KEY,
+    order_id INT REFERENCES orders(order_id) ON DELETE CASCADE,
+    method payment_method_enum NOT NULL,
+    gift_card_id INT REFERENCES gift_cards(gift_card_id),  -- только для оплаты подарочной картой
+    amount DECIMAL(10,2) NOT NULL CHECK(amount>0),
+    tendered DECIMAL(10,2) NOT NULL CHECK(tendered>=amount),
+    change_due DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK(change_due>=0),
+    paid_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Таблица gift_card_transactions: журнал выпуска, пополнений и списаний по картам
+CREATE TABLE gift_card_transactions(
+    transaction_id SERIAL PRIMARY KEY,
+    gift_card_id INT NOT NULL REFERENCES gift_cards(gift_card_id) ON DELETE CASCADE,
+    transaction_type gift_card_transaction_enum NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK(amount<>0),  -- списания отрицательные
+    payment_id INT REFERENCES payments(payment_id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Таблица promotions: скидки без кода применяются автоматически,
+-- скидки с кодом только по промокоду
+CREATE TABLE promotions(
+    promotion_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    code VARCHAR(50) UNIQUE,
+    discount_type discount_type_enum NOT NULL,
+    value DECIMAL(10,2) NOT NULL CHECK(value>0),
+    scope promotion_scope_enum NOT NULL DEFAULT 'order',
+    tag TEXT,
+    menu_item_id INT REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    usage_limit INT CHECK(usage_limit>0),
+    times_used INT NOT NULL DEFAULT 0 CHECK(times_used>=0),
+    valid_from TIMESTAMPTZ,
+    valid_until TIMESTAMPTZ,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CHECK(discount_type<>'percentage' OR value<=100),
+    CHECK(scope<>'tag' OR tag IS NOT NULL),
+    CHECK(scope<>'item' OR menu_item_id IS NOT NULL)
+);
+
+-- Таблица order_discounts: скидки, примененные к заказу
+CREATE TABLE order_discounts(
+    id SERIAL PRIMARY KEY,
+    order_id INT REFERENCES orders(order_id) ON DELETE CASCADE,
+    promotion_id INT REFERENCES promotions(promotion_id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    code VARCHAR(50),
+    amount DECIMAL(10,2) NOT NULL CHECK(amount>0)
+);
+
+-- Таблица tax_rates: ставка без тега применяется ко всем позициям.
+-- inclusive = налог уже входит в цену меню и не добавляется к сумме заказа
+CREATE TABLE tax_rates(
+    tax_rate_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    rate DECIMAL(5,2) NOT NULL CHECK(rate>0 AND rate<=100),
+    tag TEXT,
+    inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- Таблица order_taxes: налоги заказа по ставкам
+CREATE TABLE order_taxes(
+    id SERIAL PRIMARY KEY,
+    order_id INT REFERENCES orders(order_id) ON DELETE CASCADE,
+    tax_rate_id INT REFERENCES tax_rates(tax_rate_id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    rate DECIMAL(5,2) NOT NULL,
+    inclusive BOOLEAN NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK(amount>0)
+);
+
+-- Таблица loyalty_ledger: каждое начисление и списание баллов, баланс = SUM(points)
+CREATE TABLE loyalty_ledger(
+    entry_id SERIAL PRIMARY KEY,
+    loyalty_id VARCHAR(64) NOT NULL,
+    order_id INT REFERENCES orders(order_id) ON DELETE SET NULL,
+    entry_type loyalty_entry_enum NOT NULL,
+    points INT NOT NULL CHECK(points<>0),
+    note TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Таблица refunds: полные и частичные возвраты по закрытым заказам
+CREATE TABLE refunds(
+    refund_id SERIAL PRIMARY KEY,
+    order_id INT REFERENCES orders(order_id) ON DELETE CASCADE,
+    reason refund_reason_enum NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK(amount>0),
+    restocked BOOLEAN NOT NULL DEFAULT FALSE,
+    note TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Таблица refund_items: возвращенные позиции заказа
+CREATE TABLE refund_items(
+    id SERIAL PRIMARY KEY,
+    refund_id INT REFERENCES refunds(refund_id) ON DELETE CASCADE,
+    order_item_id INT REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK(quantity>0),
+    amount DECIMAL(10,2) NOT NULL CHECK(amount>=0)
 );
 
 -- Таблица inventory
@@ -35,6 +192,7 @@ CREATE TABLE inventory(
     inventory_id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL UNIQUE,
     stock_level DECIMAL(10,2) NOT NULL CHECK(stock_level>=0),
+    reserved_level DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK(reserved_level>=0),  -- отложено под открытые заказы
     last_updated TIMESTAMPTZ DEFAULT NOW(),
     reorder_level DECIMAL(10,2) NOT NULL CHECK(reorder_level>=0)
 );
@@ -47,11 +205,58 @@ CREATE TABLE menu_item_ingredients(
     quantity DECIMAL(10,2) NOT NULL CHECK(quantity>0)
 );
 
+-- Таблица menu_item_sizes: цена и множитель рецепта для каждого размера.
+-- Без записи размер medium продается по menu_items.price с базовым рецептом
+CREATE TABLE menu_item_sizes(
+    id SERIAL PRIMARY KEY,
+    menu_item_id INT REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    size item_size_enum NOT NULL,
+    price DECIMAL(10,2) NOT NULL CHECK(price>0),
+    ingredient_multiplier DECIMAL(4,2) NOT NULL DEFAULT 1 CHECK(ingredient_multiplier>0),
+    UNIQUE(menu_item_id, size)
+);
+
+-- Таблица menu_item_modifiers: платные опции к позициям меню
+CREATE TABLE menu_item_modifiers(
+    modifier_id SERIAL PRIMARY KEY,
+    menu_item_id INT REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    UNIQUE(menu_item_id, name)
+);
+
+-- Таблица modifier_ingredients: отрицательное количество убирает ингредиент из рецепта
+CREATE TABLE modifier_ingredients(
+    id SERIAL PRIMARY KEY,
+    modifier_id INT REFERENCES menu_item_modifiers(modifier_id) ON DELETE CASCADE,
+    inventory_id INT REFERENCES inventory(inventory_id) ON DELETE CASCADE,
+    quantity DECIMAL(10,2) NOT NULL CHECK(quantity<>0)
+);
+
+-- Таблица order_item_modifiers: выбранные опции с ценой на момент заказа
+CREATE TABLE order_item_modifiers(
+    id SERIAL PRIMARY KEY,
+    order_item_id INT REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    modifier_id INT REFERENCES menu_item_modifiers(modifier_id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL
+);
+
+-- Таблица order_reservations: ингредиенты, отложенные под заказ
+CREATE TABLE order_reservations(
+    order_id INT REFERENCES orders(order_id) ON DELETE CASCADE,
+    inventory_id INT REFERENCES inventory(inventory_id) ON DELETE CASCADE,
+    quantity DECIMAL(10,2) NOT NULL CHECK(quantity>0),
+    PRIMARY KEY (order_id, inventory_id)
+);
+
 -- Таблица inventory_transactions
 CREATE TABLE inventory_transactions(
     transaction_id SERIAL PRIMARY KEY,
     inventory_id INT REFERENCES inventory(inventory_id) ON DELETE CASCADE,
     quantity DECIMAL(10,2) CHECK(quantity>=0),
+    transaction_type inventory_transaction_enum NOT NULL DEFAULT 'adjustment',
+    order_id INT REFERENCES orders(order_id) ON DELETE SET NULL,
     transaction_date TIMESTAMPTZ DEFAULT NOW()
 );
 
@@ -72,37 +277,96 @@ CREATE TABLE price_history (
     changed_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+-- Таблица idempotency_keys: ответы на запросы с заголовком Idempotency-Key,
+-- повторный запрос с тем же ключом получает сохранённый ответ
+CREATE TABLE idempotency_keys(
+    idempotency_key VARCHAR(255) PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,         -- sha256 метода, пути и тела запроса
+    status_code INT,                        -- NULL, пока запрос выполняется
+    response_body BYTEA,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 CREATE INDEX idx_orders_status ON orders(status);
+CREATE INDEX idx_orders_customer_id ON orders(customer_id);
 CREATE INDEX idx_orders_order_date ON orders(order_date);
+CREATE INDEX idx_orders_pickup_at ON orders(pickup_at) WHERE status = 'scheduled';
+CREATE INDEX idx_orders_total_amount ON orders(total_amount);
+
+CREATE INDEX idx_order_items_order_id ON order_items(order_id);
+CREATE INDEX idx_order_items_item_name ON order_items(LOWER(item_name));
 
 CREATE INDEX idx_menu_items_name_ft ON menu_items USING GIN (to_tsvector('english', name));
 CREATE INDEX idx_menu_items_description_ft ON menu_items USING GIN (to_tsvector('english', description));
 CREATE INDEX idx_menu_items_tags ON menu_items USING GIN (tags);
 
+CREATE INDEX idx_payments_order_id ON payments(order_id);
+CREATE INDEX idx_gift_card_transactions_gift_card_id ON gift_card_transactions(gift_card_id);
+
+CREATE INDEX idx_promotions_code ON promotions(code);
+CREATE INDEX idx_order_discounts_order_id ON order_discounts(order_id);
+
+CREATE INDEX idx_order_taxes_order_id ON order_taxes(order_id);
+
+CREATE INDEX idx_loyalty_ledger_loyalty_id ON loyalty_ledger(loyalty_id);
+CREATE INDEX idx_loyalty_ledger_order_id ON loyalty_ledger(order_id);
+
+CREATE INDEX idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX idx_refund_items_order_item_id ON refund_items(order_item_id);
+
 CREATE INDEX idx_inventory_name ON inventory(name);
 CREATE INDEX idx_inventory_stock_level ON inventory(stock_level);
 
+CREATE INDEX idx_order_reservations_inventory_id ON order_reservations(inventory_id);
+
 CREATE INDEX idx_inventory_transactions_inventory_id ON inventory_transactions(inventory_id);
 CREATE INDEX idx_inventory_transactions_date ON inventory_transactions(transaction_date);
 
 CREATE INDEX idx_order_status_history_order_id ON order_status_history(order_id);
 CREATE INDEX idx_order_status_history_composite ON order_status_history(order_id, changed_at);
 
+CREATE INDEX idx_modifier_ingredients_modifier_id ON modifier_ingredients(modifier_id);
+CREATE INDEX idx_order_item_modifiers_order_item_id ON order_item_modifiers(order_item_id);
+
 CREATE INDEX idx_price_history_menu_item_id ON price_history(menu_item_id);
 CREATE INDEX idx_price_history_changed_at ON price_history(changed_at);
 
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+
+-- Вставка данных в customers
+INSERT INTO customers (name, phone, email, preferences) VALUES
+('Alice Smith', '+77010000001', 'alice@example.com', '{"milk": "oat", "sugar": false}'),
+('Emma Thomas', '+77010000002', NULL, '{"size": "large"}');
+
 -- Вставка данных в orders (теперь с customer_name)
-INSERT INTO orders (customer_name, order_date, status, total_amount, special_instructions) VALUES
-('John Doe', '2023-11-14 13:15:45', 'active', 17.49, '{"note": "Extra cheese and olives"}'),
-('Alice Smith', '2024-02-05 09:02:11', 'closed', 9.25, '{"note": "No onions, add pickles"}'),
-('Bob Johnson', '2022-08-07 18:35:50', 'active', 14.00, '{"note": "Gluten-free crust"}'),
-('Pizza Davis', '2025-01-10 08:45:30', 'closed', 26.99, '{"note": "Medium rare, side salad"}'),
-('John Brown', '2023-06-14 21:10:00', 'active', 6.50, '{"note": "Spicy, extra jalapenos"}'),
-('Sophia Wilson', '2024-09-17 12:50:15', 'closed', 8.25, '{"note": "No mayo, extra mustard"}'),
-('Daniel Martinez', '2021-03-19 07:30:40', 'active', 11.49, '{"note": "Extra sauce on the side"}'),
-('Olivia Taylor', '2022-12-21 20:20:35', 'closed', 7.25, '{"note": "Vegan option, no nuts"}'),
-('James Anderson', '2025-05-25 11:55:14', 'active', 4.75, '{"note": "Well-done, no salt"}'),
-('Emma Thomas', '2023-11-14 23:15:05', 'closed', 5.50, '{"note": "With sprinkles and syrup"}');
+INSERT INTO orders (customer_name, order_date, status, subtotal, total_amount, special_instructions) VALUES
+('John Doe', '2023-11-14 13:15:45', 'pending', 17.49, 17.49, '{"note": "Extra cheese and olives"}'),
+('Alice Smith', '2024-02-05 09:02:11', 'closed', 9.25, 9.25, '{"note": "No onions, add pickles"}'),
+('Bob Johnson', '2022-08-07 18:35:50', 'preparing', 14.00, 14.00, '{"note": "Gluten-free crust"}'),
+('Pizza Davis', '2025-01-10 08:45:30', 'closed', 26.99, 26.99, '{"note": "Medium rare, side salad"}'),
+('John Brown', '2023-06-14 21:10:00', 'ready', 6.50, 6.50, '{"note": "Spicy, extra jalapenos"}'),
+('Sophia Wilson', '2024-09-17 12:50:15', 'closed', 8.25, 8.25, '{"note": "No mayo, extra mustard"}'),
+('Daniel Martinez', '2021-03-19 07:30:40', 'pending', 11.49, 11.49, '{"note": "Extra sauce on the side"}'),
+('Olivia Taylor', '2022-12-21 20:20:35', 'closed', 7.25, 7.25, '{"note": "Vegan option, no nuts"}'),
+('James Anderson', '2025-05-25 11:55:14', 'preparing', 4.75, 4.75, '{"note": "Well-done, no salt"}'),
+('Emma Thomas', '2023-11-14 23:15:05', 'closed', 5.50, 5.50, '{"note": "With sprinkles and syrup"}');
+
+-- Привязка заказов к профилям клиентов
+UPDATE orders SET customer_id = c.customer_id
+FROM customers c
+WHERE c.name = orders.customer_name;
+
+-- Вставка данных в payments для закрытых заказов
+INSERT INTO payments (order_id, method, amount, tendered, change_due, paid_at)
+SELECT
+    order_id,
+    CASE WHEN order_id % 4 = 0 THEN 'cash'::payment_method_enum ELSE 'card'::payment_method_enum END,
+    total_amount,
+    CASE WHEN order_id % 4 = 0 THEN CEIL(total_amount) ELSE total_amount END,
+    CASE WHEN order_id % 4 = 0 THEN CEIL(total_amount) - total_amount ELSE 0 END,
+    order_date + INTERVAL '8 minutes'
+FROM orders
+WHERE status = 'closed';
 
 -- Вставка данных в menu_items
 INSERT INTO menu_items (name, description, price, tags) VALUES
@@ -117,6 +381,17 @@ INSERT INTO menu_items (name, description, price, tags) VALUES
 ('Ice Cream', 'Vanilla ice cream', 4.99, ARRAY['dessert', 'sweet']),
 ('Sandwich', 'Club sandwich', 7.99, ARRAY['bread', 'snack']);
 
+-- Вставка данных в promotions
+INSERT INTO promotions (name, code, discount_type, value, scope, tag, menu_item_id, usage_limit, valid_from, valid_until) VALUES
+('Welcome discount', 'WELCOME10', 'percentage', 10, 'order', NULL, NULL, 500, '2024-01-01', NULL),
+('Healthy choice', 'HEALTHY2', 'fixed', 2.00, 'tag', 'healthy', NULL, NULL, '2024-01-01', '2026-12-31'),
+('Fries happy hour', NULL, 'percentage', 50, 'item', NULL, 8, NULL, '2025-06-01', '2025-06-30');
+
+-- Вставка данных в tax_rates
+INSERT INTO tax_rates (name, rate, tag, inclusive, active) VALUES
+('VAT', 12.00, NULL, TRUE, TRUE),
+('Sugar levy', 5.00, 'sweet', FALSE, TRUE);
+
 -- Вставка данных в inventory
 INSERT INTO inventory (name, stock_level, reorder_level) VALUES
 ('Cheese', 100,  10),
@@ -164,18 +439,50 @@ INSERT INTO menu_item_ingredients (menu_item_id, inventory_id, quantity) VALUES
 (10, 1, 1),  -- Sandwich -> Cheese
 (10, 2, 1);  -- Sandwich -> Beef
 
+-- Вставка данных в menu_item_sizes
+INSERT INTO menu_item_sizes (menu_item_id, size, price, ingredient_multiplier) VALUES
+(1, 'small', 9.99, 0.75),
+(1, 'medium', 12.99, 1),
+(1, 'large', 15.99, 1.5),
+(7, 'small', 4.49, 0.5),
+(7, 'large', 7.49, 1.5),
+(9, 'small', 3.49, 0.5),
+(9, 'large', 6.49, 2);
+
+-- Вставка данных в menu_item_modifiers
+INSERT INTO menu_item_modifiers (menu_item_id, name, price_delta) VALUES
+(1, 'Extra cheese', 1.50),
+(2, 'Double beef', 3.00),
+(2, 'No cheese', -0.50),
+(3, 'Without milk', 0.00),
+(9, 'Extra scoop', 1.25);
+
+-- Вставка данных в modifier_ingredients
+INSERT INTO modifier_ingredients (modifier_id, inventory_id, quantity) VALUES
+(1, 1, 1),   -- Extra cheese -> +Cheese
+(2, 2, 1),   -- Double beef -> +Beef
+(3, 1, -1),  -- No cheese -> -Cheese
+(4, 9, -1),  -- Without milk -> -Milk
+(5, 9, 1),   -- Extra scoop -> +Milk
+(5, 1, 1);   -- Extra scoop -> +Cheese
+
 -- Вставка данных в order_items
-INSERT INTO order_items (menu_item_id, order_id, customizations, price_at_order_time, quantity) VALUES
-(6, 1, '{"extra_cheese": true}', 12.99, 5),
-(6, 2, '{"no_onions": true}', 8.99, 7),
-(5, 3, '{"gluten_free": true}', 10.99, 4),
-(4, 4, '{"extra_dressing": true}', 6.99, 2),
-(3, 5, '{"spicy": true}', 15.99, 3),
-(3, 6, '{"medium_rare": true}', 24.99, 2),
-(1, 7, '{"extra_sauce": true}', 5.99, 1),
-(2, 8, '{"no_salt": true}', 3.99, 4),
-(9, 9, '{"extra_sprinkles": true}', 4.99, 6),
-(10, 10, '{"no_mayo": true}', 7.99, 10);
+INSERT INTO order_items (menu_item_id, item_name, order_id, customizations, price_at_order_time, quantity)
+SELECT v.menu_item_id, mi.name, v.order_id, v.customizations::JSONB, v.price, v.quantity
+FROM (VALUES
+    (6, 1, '{"extra_cheese": true}', 12.99, 5),
+    (6, 2, '{"no_onions": true}', 8.99, 7),
+    (5, 3, '{"gluten_free": true}', 10.99, 4),
+    (4, 4, '{"extra_dressing": true}', 6.99, 2),
+    (3, 5, '{"spicy": true}', 15.99, 3),
+    (3, 6, '{"medium_rare": true}', 24.99, 2),
+    (1, 7, '{"extra_sauce": true}', 5.99, 1),
+    (2, 8, '{"no_salt": true}', 3.99, 4),
+    (9, 9, '{"extra_sprinkles": true}', 4.99, 6),
+    (10, 10, '{"no_mayo": true}', 7.99, 10)
+) AS v(menu_item_id, order_id, customizations, price, quantity)
+JOIN menu_items mi ON mi.menu_item_id = v.menu_item_id
+ORDER BY v.order_id;
 
 -- Вставка данных в inventory_transactions
 INSERT INTO inventory_transactions (inventory_id, quantity, transaction_date) VALUES
@@ -191,17 +498,20 @@ INSERT INTO inventory_transactions (inventory_id, quantity, transaction_date) VA
 (10,20,NOW());
 
 -- Вставка данных в order_status_history
-INSERT INTO order_status_history (order_id, status, changed_at) VALUES
-(1, 'active', NOW()),
-(2, 'closed', NOW()),
-(3, 'active', NOW()),
-(4, 'closed', NOW()),
-(5, 'active', NOW()),
-(6, 'closed', NOW()),
-(7, 'active', NOW()),
-(8, 'closed', NOW()),
-(9, 'active', NOW()),
-(10, 'closed', NOW());
+INSERT INTO order_status_history (order_id, status, changed_at)
+SELECT order_id, 'pending', order_date FROM orders;
+
+INSERT INTO order_status_history (order_id, status, changed_at)
+SELECT order_id, 'preparing', order_date + INTERVAL '2 minutes' FROM orders
+WHERE status IN ('preparing', 'ready', 'closed');
+
+INSERT INTO order_status_history (order_id, status, changed_at)
+SELECT order_id, 'ready', order_date + INTERVAL '6 minutes' FROM orders
+WHERE status IN ('ready', 'closed');
+
+INSERT INTO order_status_history (order_id, status, changed_at)
+SELECT order_id, 'closed', order_date + INTERVAL '9 minutes' FROM orders
+WHERE status = 'closed';
 
 -- Вставка данных в price_history
 INSERT INTO price_history (menu_item_id, old_price, new_price, changed_at) VALUES
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"frappuccino/config"
 	"frappuccino/internal/dal"
 	"frappuccino/internal/routes"
+	"frappuccino/internal/service"
 )
 
 func Start() {
@@ -22,6 +24,13 @@ func Start() {
 	}
 	config.Logger.Info("Parsed flags")
 
+	location, err := config.LoadLocation(*config.Timezone)
+	if err != nil {
+		config.Logger.Error("Invalid timezone", "timezone", *config.Timezone, "error", err)
+		log.Fatal(err)
+	}
+	config.BusinessLocation = location
+
 	config.Logger.Info("Trying to connect to DB")
 	db, err := dal.ConnectionDB()
 	if err != nil {
@@ -31,9 +40,18 @@ func Start() {
 	defer db.Close()
 	config.Logger.Info("Connected to DB")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	orderEvents := service.NewOrderEvents()
+
+	scheduler := service.NewScheduler(dal.NewOrderRepo(db), orderEvents, *config.SchedulerInterval, *config.PreorderLeadTime)
+	go scheduler.Run(ctx)
+	config.Logger.Info("Started scheduled order checks")
+
 	mux := http.NewServeMux()
 
-	routes.Routes(mux, db)
+	routes.Routes(mux, db, orderEvents)
 	config.Logger.Info("DataBase connection established")
 	log.Fatal(http.ListenAndServe(":8080", mux))
 }
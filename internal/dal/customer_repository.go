@@ -0,0 +1,153 @@
+package dal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	model "frappuccino/models"
+
+	"github.com/lib/pq"
+)
+
+type CustomerRepository interface {
+	Add(customer model.Customer) (int, error)
+	GetAll() ([]model.Customer, error)
+	GetByID(id int) (model.Customer, error)
+	Update(id int, customer model.Customer) error
+	Delete(id int) error
+}
+
+type Customer struct {
+	db *sql.DB
+}
+
+func NewCustomerRepo(db *sql.DB) *Customer {
+	return &Customer{db: db}
+}
+
+var (
+	ErrCustomerNotFound = errors.New("customer_not_found")
+	ErrCustomerExists   = errors.New("customer_already_exists")
+)
+
+const customerColumns = `customer_id, name, COALESCE(phone, ''), COALESCE(email, ''), preferences, created_at`
+
+func (c *Customer) Add(customer model.Customer) (int, error) {
+	preferences, err := jsonbValue(customer.Preferences)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = c.db.QueryRow(`
+		INSERT INTO customers (name, phone, email, preferences)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4)
+		RETURNING customer_id
+	`, customer.Name, customer.Phone, customer.Email, preferences).Scan(&id)
+	return id, customerError(err)
+}
+
+func (c *Customer) GetAll() ([]model.Customer, error) {
+	rows, err := c.db.Query(`SELECT ` + customerColumns + ` FROM customers ORDER BY customer_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	customers := []model.Customer{}
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	return customers, rows.Err()
+}
+
+func (c *Customer) GetByID(id int) (model.Customer, error) {
+	customer, err := scanCustomer(c.db.QueryRow(`SELECT `+customerColumns+` FROM customers WHERE customer_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Customer{}, fmt.Errorf("%w: customer %d", ErrCustomerNotFound, id)
+	}
+	return customer, err
+}
+
+func (c *Customer) Update(id int, customer model.Customer) error {
+	preferences, err := jsonbValue(customer.Preferences)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.db.Exec(`
+		UPDATE customers
+		SET name = $1, phone = NULLIF($2, ''), email = NULLIF($3, ''), preferences = $4
+		WHERE customer_id = $5
+	`, customer.Name, customer.Phone, customer.Email, preferences, id)
+	if err != nil {
+		return customerError(err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: customer %d", ErrCustomerNotFound, id)
+	}
+	return nil
+}
+
+// Delete removes a customer profile. Their orders are kept and stay under the
+// name they were placed with.
+func (c *Customer) Delete(id int) error {
+	result, err := c.db.Exec(`DELETE FROM customers WHERE customer_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: customer %d", ErrCustomerNotFound, id)
+	}
+	return nil
+}
+
+func scanCustomer(row rowScanner) (model.Customer, error) {
+	var customer model.Customer
+	var preferences []byte
+	if err := row.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Email, &preferences, &customer.CreatedAt); err != nil {
+		return model.Customer{}, err
+	}
+	if err := json.Unmarshal(preferences, &customer.Preferences); err != nil {
+		return model.Customer{}, err
+	}
+	return customer, nil
+}
+
+// customerError reports a duplicate phone or email as ErrCustomerExists.
+func customerError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("%w: %s", ErrCustomerExists, pqErr.Message)
+	}
+	return err
+}
+
+// resolveCustomerName returns the name to store on an order. A walk-in order
+// keeps the name it was given; an order linked to a customer falls back to
+// the name on the profile.
+func resolveCustomerName(tx *sql.Tx, customerID int, name string) (string, error) {
+	if customerID == 0 {
+		return name, nil
+	}
+
+	var profileName string
+	err := tx.QueryRow(`SELECT name FROM customers WHERE customer_id = $1`, customerID).Scan(&profileName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: customer %d", ErrCustomerNotFound, customerID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if name == "" {
+		return profileName, nil
+	}
+	return name, nil
+}
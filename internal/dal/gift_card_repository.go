@@ -0,0 +1,208 @@
+package dal
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	model "frappuccino/models"
+
+	"github.com/lib/pq"
+)
+
+type GiftCardRepository interface {
+	Issue(code string, amount model.Money, loc *time.Location) (model.GiftCard, error)
+	GetByCode(code string, loc *time.Location) (model.GiftCard, error)
+	TopUp(code string, amount model.Money, loc *time.Location) (model.GiftCard, error)
+}
+
+type GiftCard struct {
+	db *sql.DB
+}
+
+func NewGiftCardRepo(db *sql.DB) *GiftCard {
+	return &GiftCard{db: db}
+}
+
+var (
+	ErrGiftCardNotFound     = errors.New("gift_card_not_found")
+	ErrGiftCardExists       = errors.New("gift_card_already_exists")
+	ErrNotEnoughGiftBalance = errors.New("insufficient_gift_card_balance")
+)
+
+// Issue creates a card with an opening balance. An empty code is replaced with
+// a random one.
+func (g *GiftCard) Issue(code string, amount model.Money, loc *time.Location) (model.GiftCard, error) {
+	if code == "" {
+		var err error
+		if code, err = newGiftCardCode(); err != nil {
+			return model.GiftCard{}, err
+		}
+	}
+
+	tx, err := g.db.Begin()
+	if err != nil {
+		return model.GiftCard{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var id int
+	err = tx.QueryRow(`INSERT INTO gift_cards (code, balance) VALUES ($1, $2) RETURNING gift_card_id`, code, amount).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			err = fmt.Errorf("%w: %s", ErrGiftCardExists, code)
+		}
+		return model.GiftCard{}, err
+	}
+
+	if err = addGiftCardTransaction(tx, id, model.GiftCardIssue, amount, 0); err != nil {
+		return model.GiftCard{}, err
+	}
+
+	var card model.GiftCard
+	if card, err = giftCardByCode(tx, code, loc); err != nil {
+		return model.GiftCard{}, err
+	}
+	err = tx.Commit()
+	return card, err
+}
+
+func (g *GiftCard) GetByCode(code string, loc *time.Location) (model.GiftCard, error) {
+	tx, err := g.db.Begin()
+	if err != nil {
+		return model.GiftCard{}, err
+	}
+	defer tx.Rollback()
+
+	return giftCardByCode(tx, code, loc)
+}
+
+func (g *GiftCard) TopUp(code string, amount model.Money, loc *time.Location) (model.GiftCard, error) {
+	tx, err := g.db.Begin()
+	if err != nil {
+		return model.GiftCard{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var id int
+	err = tx.QueryRow(`UPDATE gift_cards SET balance = balance + $1 WHERE code = $2 RETURNING gift_card_id`, amount, code).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = fmt.Errorf("%w: %s", ErrGiftCardNotFound, code)
+	}
+	if err != nil {
+		return model.GiftCard{}, err
+	}
+
+	if err = addGiftCardTransaction(tx, id, model.GiftCardTopUp, amount, 0); err != nil {
+		return model.GiftCard{}, err
+	}
+
+	var card model.GiftCard
+	if card, err = giftCardByCode(tx, code, loc); err != nil {
+		return model.GiftCard{}, err
+	}
+	err = tx.Commit()
+	return card, err
+}
+
+// giftCardByCode loads a card with its transactions, newest first, with the
+// times in loc.
+func giftCardByCode(tx *sql.Tx, code string, loc *time.Location) (model.GiftCard, error) {
+	var card model.GiftCard
+	err := tx.QueryRow(`SELECT gift_card_id, code, balance, created_at FROM gift_cards WHERE code = $1`, code).
+		Scan(&card.ID, &card.Code, &card.Balance, &card.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.GiftCard{}, fmt.Errorf("%w: %s", ErrGiftCardNotFound, code)
+	}
+	if err != nil {
+		return model.GiftCard{}, err
+	}
+	card.CreatedAt = card.CreatedAt.In(loc)
+
+	rows, err := tx.Query(`
+		SELECT transaction_id, transaction_type, amount, COALESCE(payment_id, 0), created_at
+		FROM gift_card_transactions
+		WHERE gift_card_id = $1
+		ORDER BY created_at DESC, transaction_id DESC
+	`, card.ID)
+	if err != nil {
+		return model.GiftCard{}, err
+	}
+	defer rows.Close()
+
+	card.Transactions = []model.GiftCardTransaction{}
+	for rows.Next() {
+		var transaction model.GiftCardTransaction
+		if err := rows.Scan(&transaction.ID, &transaction.Type, &transaction.Amount, &transaction.PaymentID, &transaction.CreatedAt); err != nil {
+			return model.GiftCard{}, err
+		}
+		transaction.CreatedAt = transaction.CreatedAt.In(loc)
+		card.Transactions = append(card.Transactions, transaction)
+	}
+	return card, rows.Err()
+}
+
+// redeemGiftCard takes amount off a card. With upTo the card pays as much of
+// amount as its balance allows instead of failing. The balance is only
+// lowered while it still covers the amount, so two registers spending the
+// same card at once can never take it below zero.
+func redeemGiftCard(tx *sql.Tx, code string, amount model.Money, upTo bool) (int, model.Money, error) {
+	var id int
+	var balance model.Money
+	err := tx.QueryRow(`SELECT gift_card_id, balance FROM gift_cards WHERE code = $1`, code).Scan(&id, &balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, fmt.Errorf("%w: %s", ErrGiftCardNotFound, code)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if upTo {
+		amount = min(amount, balance)
+	}
+	if amount <= 0 {
+		return 0, 0, fmt.Errorf("%w: gift card %s is empty", ErrNotEnoughGiftBalance, code)
+	}
+
+	result, err := tx.Exec(`
+		UPDATE gift_cards
+		SET balance = balance - $1
+		WHERE gift_card_id = $2 AND balance >= $1
+	`, amount, id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, 0, fmt.Errorf("%w: gift card %s does not cover %s", ErrNotEnoughGiftBalance, code, amount)
+	}
+	return id, amount, nil
+}
+
+func addGiftCardTransaction(tx *sql.Tx, giftCardID int, transactionType string, amount model.Money, paymentID int) error {
+	_, err := tx.Exec(`
+		INSERT INTO gift_card_transactions (gift_card_id, transaction_type, amount, payment_id)
+		VALUES ($1, $2, $3, NULLIF($4, 0))
+	`, giftCardID, transactionType, amount, paymentID)
+	return err
+}
+
+// newGiftCardCode returns a random 16 character code.
+func newGiftCardCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(b)), nil
+}
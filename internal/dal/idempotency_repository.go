@@ -0,0 +1,91 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	model "frappuccino/models"
+)
+
+type IdempotencyRepository interface {
+	Claim(key, requestHash string, expiredBefore time.Time) (model.IdempotentResponse, bool, error)
+	Complete(key string, statusCode int, body []byte) error
+	Release(key string) error
+}
+
+type Idempotency struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepo(db *sql.DB) *Idempotency {
+	return &Idempotency{db: db}
+}
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency_key_reused")
+	ErrIdempotencyKeyInProgress = errors.New("idempotency_key_in_progress")
+)
+
+// Claim takes the key for a request. It returns true when the key is new and
+// the request should be handled, or the stored response when the same request
+// was already handled. Keys created before expiredBefore are forgotten first.
+func (i *Idempotency) Claim(key, requestHash string, expiredBefore time.Time) (model.IdempotentResponse, bool, error) {
+	if _, err := i.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < $1`, expiredBefore); err != nil {
+		return model.IdempotentResponse{}, false, err
+	}
+
+	result, err := i.db.Exec(`
+		INSERT INTO idempotency_keys (idempotency_key, request_hash)
+		VALUES ($1, $2)
+		ON CONFLICT (idempotency_key) DO NOTHING
+	`, key, requestHash)
+	if err != nil {
+		return model.IdempotentResponse{}, false, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 1 {
+		return model.IdempotentResponse{}, true, nil
+	}
+
+	stored := model.IdempotentResponse{Key: key}
+	var statusCode sql.NullInt64
+	err = i.db.QueryRow(`
+		SELECT request_hash, status_code, response_body, created_at
+		FROM idempotency_keys
+		WHERE idempotency_key = $1
+	`, key).Scan(&stored.RequestHash, &statusCode, &stored.Body, &stored.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// released between the insert and the select; let the caller retry
+			return model.IdempotentResponse{}, false, fmt.Errorf("%w: key %q", ErrIdempotencyKeyInProgress, key)
+		}
+		return model.IdempotentResponse{}, false, err
+	}
+
+	if stored.RequestHash != requestHash {
+		return model.IdempotentResponse{}, false, fmt.Errorf("%w: key %q was used for a different request", ErrIdempotencyKeyReused, key)
+	}
+	if !statusCode.Valid {
+		return model.IdempotentResponse{}, false, fmt.Errorf("%w: key %q", ErrIdempotencyKeyInProgress, key)
+	}
+	stored.StatusCode = int(statusCode.Int64)
+	return stored, false, nil
+}
+
+// Complete stores the response to the request that claimed the key.
+func (i *Idempotency) Complete(key string, statusCode int, body []byte) error {
+	_, err := i.db.Exec(`
+		UPDATE idempotency_keys
+		SET status_code = $2, response_body = $3
+		WHERE idempotency_key = $1
+	`, key, statusCode, body)
+	return err
+}
+
+// Release gives up a claimed key without a response, so the request can be
+// sent again with it.
+func (i *Idempotency) Release(key string) error {
+	_, err := i.db.Exec(`DELETE FROM idempotency_keys WHERE idempotency_key = $1 AND status_code IS NULL`, key)
+	return err
+}
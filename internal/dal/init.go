@@ -47,11 +47,19 @@ func Helper() {
 		`Coffee Shop Management System
 
 Usage:
-hot-coffee [--port <N>] [--dir <S>] 
+hot-coffee [--port <N>] [--dir <S>] [--loyalty-earn-rate <F>] [--loyalty-point-value <F>]
+           [--preorder-lead-time <D>] [--scheduler-interval <D>] [--idempotency-key-ttl <D>]
+           [--timezone <S>]
 hot-coffee --help
 
 Options:
---help       Show this screen.
---port N     Port number.
---dir S      Path to the data directory.`)
+--help                   Show this screen.
+--port N                 Port number.
+--dir S                  Path to the data directory.
+--loyalty-earn-rate F    Loyalty points earned per unit of currency paid.
+--loyalty-point-value F  Discount given per redeemed loyalty point.
+--preorder-lead-time D   How long before pickup a pre-order is queued, e.g. 15m.
+--scheduler-interval D   How often pre-orders are checked, e.g. 1m.
+--idempotency-key-ttl D  How long responses are kept for Idempotency-Key replays, e.g. 24h.
+--timezone S             Business timezone for dates and reports, e.g. Asia/Almaty.`)
 }
@@ -10,8 +10,8 @@ import (
 
 type InventoryRepository interface {
 	Add(inventoryItem model.InventoryItem) (model.InventoryItem, error)
-	GetAll() ([]model.InventoryItem, error)
-	GetByID(id int) (model.InventoryItem, error)
+	GetAll(loc *time.Location) ([]model.InventoryItem, error)
+	GetByID(id int, loc *time.Location) (model.InventoryItem, error)
 	Update(inventoryItem model.InventoryItem) error
 	Delete(id int) error
 	CountInventory(sortBy string, page, pageSize int) (model.CountInventory, error)
@@ -61,9 +61,9 @@ func (i *Inventory) Add(inventoryItem model.InventoryItem) (model.InventoryItem,
 	return inventoryItem, nil
 }
 
-func (i *Inventory) GetAll() ([]model.InventoryItem, error) {
+func (i *Inventory) GetAll(loc *time.Location) ([]model.InventoryItem, error) {
 	query := `
-		SELECT inventory_id, name, stock_level, reorder_level, last_updated
+		SELECT inventory_id, name, stock_level, reserved_level, reorder_level, last_updated
 		FROM inventory
 	`
 
@@ -80,15 +80,15 @@ func (i *Inventory) GetAll() ([]model.InventoryItem, error) {
 		var stock float64
 		var lastUpdated time.Time
 
-		if err := rows.Scan(&id, &inventoryItem.Name, &stock, &inventoryItem.ReorderLevel, &lastUpdated); err != nil {
+		if err := rows.Scan(&id, &inventoryItem.Name, &stock, &inventoryItem.Reserved, &inventoryItem.ReorderLevel, &lastUpdated); err != nil {
 			return nil, err
 		}
 
-		loc, _ := time.LoadLocation("Asia/Almaty")
 		lastUpdated = lastUpdated.In(loc)
 
 		inventoryItem.IngredientID = &id
 		inventoryItem.StockLevel = &stock
+		inventoryItem.Available = stock - inventoryItem.Reserved
 		inventoryItem.LastUpdated = lastUpdated
 
 		inventoryItems = append(inventoryItems, inventoryItem)
@@ -96,9 +96,9 @@ func (i *Inventory) GetAll() ([]model.InventoryItem, error) {
 	return inventoryItems, nil
 }
 
-func (i *Inventory) GetByID(id int) (model.InventoryItem, error) {
+func (i *Inventory) GetByID(id int, loc *time.Location) (model.InventoryItem, error) {
 	query := `
-		SELECT inventory_id, name, stock_level, reorder_level, last_updated
+		SELECT inventory_id, name, stock_level, reserved_level, reorder_level, last_updated
 		FROM inventory
 		WHERE inventory_id = $1
 	`
@@ -109,7 +109,7 @@ func (i *Inventory) GetByID(id int) (model.InventoryItem, error) {
 	var stock float64
 	var lastUpdated time.Time
 
-	err := row.Scan(&invID, &inventoryItem.Name, &stock, &inventoryItem.ReorderLevel, &lastUpdated)
+	err := row.Scan(&invID, &inventoryItem.Name, &stock, &inventoryItem.Reserved, &inventoryItem.ReorderLevel, &lastUpdated)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return model.InventoryItem{}, errors.New("inventory item not found")
@@ -117,11 +117,11 @@ func (i *Inventory) GetByID(id int) (model.InventoryItem, error) {
 		return model.InventoryItem{}, err
 	}
 
-	loc, _ := time.LoadLocation("Asia/Almaty")
 	lastUpdated = lastUpdated.In(loc)
 
 	inventoryItem.IngredientID = &invID
 	inventoryItem.StockLevel = &stock
+	inventoryItem.Available = stock - inventoryItem.Reserved
 	inventoryItem.LastUpdated = lastUpdated
 
 	return inventoryItem, nil
@@ -138,7 +138,7 @@ func (i *Inventory) Update(inventoryItem model.InventoryItem) error {
 		}
 	}()
 
-	ingredient, err := i.GetByID(*inventoryItem.IngredientID)
+	ingredient, err := i.GetByID(*inventoryItem.IngredientID, time.UTC)
 	if inventoryItem.StockLevel == nil {
 		inventoryItem.StockLevel = ingredient.StockLevel
 	}
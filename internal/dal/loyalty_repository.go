@@ -0,0 +1,211 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"frappuccino/config"
+	model "frappuccino/models"
+)
+
+type LoyaltyRepository interface {
+	Balance(loyaltyID string) (model.LoyaltyBalance, error)
+	Ledger(loyaltyID string, loc *time.Location) (model.LoyaltyBalance, error)
+}
+
+type Loyalty struct {
+	db *sql.DB
+}
+
+func NewLoyaltyRepo(db *sql.DB) *Loyalty {
+	return &Loyalty{db: db}
+}
+
+var ErrNotEnoughPoints = errors.New("insufficient_points")
+
+func (l *Loyalty) Balance(loyaltyID string) (model.LoyaltyBalance, error) {
+	balance := model.LoyaltyBalance{LoyaltyID: loyaltyID}
+	err := l.db.QueryRow(`SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE loyalty_id = $1`, loyaltyID).Scan(&balance.Balance)
+	return balance, err
+}
+
+// Ledger returns the balance together with every entry, newest first.
+func (l *Loyalty) Ledger(loyaltyID string, loc *time.Location) (model.LoyaltyBalance, error) {
+	balance, err := l.Balance(loyaltyID)
+	if err != nil {
+		return model.LoyaltyBalance{}, err
+	}
+
+	rows, err := l.db.Query(`
+		SELECT entry_id, loyalty_id, COALESCE(order_id, 0), entry_type, points, COALESCE(note, ''), created_at
+		FROM loyalty_ledger
+		WHERE loyalty_id = $1
+		ORDER BY created_at DESC, entry_id DESC
+	`, loyaltyID)
+	if err != nil {
+		return model.LoyaltyBalance{}, err
+	}
+	defer rows.Close()
+
+	balance.Entries = []model.LoyaltyEntry{}
+	for rows.Next() {
+		var entry model.LoyaltyEntry
+		if err := rows.Scan(&entry.ID, &entry.LoyaltyID, &entry.OrderID, &entry.Type, &entry.Points, &entry.Note, &entry.CreatedAt); err != nil {
+			return model.LoyaltyBalance{}, err
+		}
+		entry.CreatedAt = entry.CreatedAt.In(loc)
+		balance.Entries = append(balance.Entries, entry)
+	}
+	return balance, rows.Err()
+}
+
+// lockLoyaltyAccount serialises point changes of one account until the
+// transaction ends, so two registers can not spend the same points.
+func lockLoyaltyAccount(tx *sql.Tx, loyaltyID string) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('loyalty:' || $1))`, loyaltyID)
+	return err
+}
+
+// redeemPoints turns points into an order discount worth at most limit. Only
+// as many points as the discount needs are taken. The ledger entry is written
+// by saveOrderPricing once the order exists; callers that go on to write it
+// lock the account with lockLoyaltyAccount first.
+func redeemPoints(tx *sql.Tx, loyaltyID string, points int, limit model.Money) (model.OrderDiscount, int, error) {
+	if points <= 0 {
+		return model.OrderDiscount{}, 0, nil
+	}
+
+	var balance int
+	err := tx.QueryRow(`SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE loyalty_id = $1`, loyaltyID).Scan(&balance)
+	if err != nil {
+		return model.OrderDiscount{}, 0, err
+	}
+	if balance < points {
+		return model.OrderDiscount{}, 0, fmt.Errorf("%w: %s has %d points, %d requested", ErrNotEnoughPoints, loyaltyID, balance, points)
+	}
+
+	pointValue := model.MoneyFromFloat(*config.LoyaltyPointValue)
+	if pointValue <= 0 {
+		return model.OrderDiscount{}, 0, nil
+	}
+	points = min(points, int(limit/pointValue))
+	if points <= 0 {
+		return model.OrderDiscount{}, 0, nil
+	}
+
+	discount := model.OrderDiscount{
+		Name:   "Loyalty points",
+		Amount: pointValue.Mul(points),
+	}
+	return discount, points, nil
+}
+
+// addLoyaltyEntry appends an entry to the ledger of an account.
+func addLoyaltyEntry(tx *sql.Tx, loyaltyID string, orderID int, entryType string, points int) error {
+	if loyaltyID == "" || points == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO loyalty_ledger (loyalty_id, order_id, entry_type, points)
+		VALUES ($1, $2, $3, $4)
+	`, loyaltyID, orderID, entryType, points)
+	return err
+}
+
+// reverseRedeemedPoints gives back the points still redeemed on an order.
+func reverseRedeemedPoints(tx *sql.Tx, orderID int) error {
+	rows, err := tx.Query(`
+		SELECT loyalty_id, SUM(points)
+		FROM loyalty_ledger
+		WHERE order_id = $1 AND entry_type IN ('redeem', 'reverse')
+		GROUP BY loyalty_id
+	`, orderID)
+	if err != nil {
+		return err
+	}
+
+	redeemed := make(map[string]int)
+	for rows.Next() {
+		var loyaltyID string
+		var points int
+		if err := rows.Scan(&loyaltyID, &points); err != nil {
+			rows.Close()
+			return err
+		}
+		if points < 0 {
+			redeemed[loyaltyID] = -points
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for loyaltyID, points := range redeemed {
+		if err := addLoyaltyEntry(tx, loyaltyID, orderID, model.LoyaltyReverse, points); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reverseEarnedPoints takes back the points a closed order earned in
+// proportion to what has been refunded from it so far, tip left out as when
+// they were earned. Points already taken back by earlier refunds are counted,
+// so a full refund leaves nothing earned.
+func reverseEarnedPoints(tx *sql.Tx, orderID int) error {
+	var loyaltyID sql.NullString
+	var total, tip, refunded model.Money
+	err := tx.QueryRow(`
+		SELECT o.loyalty_id, o.total_amount, o.tip_amount,
+			COALESCE((SELECT SUM(amount) FROM refunds WHERE order_id = o.order_id), 0)
+		FROM orders o
+		WHERE o.order_id = $1
+	`, orderID).Scan(&loyaltyID, &total, &tip, &refunded)
+	if err != nil || !loyaltyID.Valid {
+		return err
+	}
+
+	var earned, taken int
+	err = tx.QueryRow(`
+		SELECT
+			COALESCE(SUM(points) FILTER (WHERE entry_type = 'earn'), 0),
+			COALESCE(SUM(points) FILTER (WHERE entry_type = 'reverse' AND points < 0), 0)
+		FROM loyalty_ledger
+		WHERE order_id = $1 AND loyalty_id = $2
+	`, orderID, loyaltyID.String).Scan(&earned, &taken)
+	if err != nil || earned <= 0 {
+		return err
+	}
+
+	base := total - tip
+	kept := 0
+	if left := base - refunded; left > 0 && base > 0 {
+		kept = int(math.Floor(float64(earned) * left.Float64() / base.Float64()))
+	}
+	if delta := kept - (earned + taken); delta < 0 {
+		return addLoyaltyEntry(tx, loyaltyID.String, orderID, model.LoyaltyReverse, delta)
+	}
+	return nil
+}
+
+// earnPoints credits the loyalty account of a closed order with points for
+// what was paid, leaving out the tip.
+func earnPoints(tx *sql.Tx, orderID int) error {
+	var loyaltyID sql.NullString
+	var total, tip model.Money
+	err := tx.QueryRow(`SELECT loyalty_id, total_amount, tip_amount FROM orders WHERE order_id = $1`, orderID).
+		Scan(&loyaltyID, &total, &tip)
+	if err != nil || !loyaltyID.Valid {
+		return err
+	}
+
+	points := int(math.Floor((total - tip).Float64() * *config.LoyaltyEarnRate))
+	if points <= 0 {
+		return nil
+	}
+	return addLoyaltyEntry(tx, loyaltyID.String, orderID, model.LoyaltyEarn, points)
+}
@@ -13,8 +13,8 @@ import (
 type MenuRepository interface {
 	GetAll() ([]model.MenuRequest, error)
 	GetByID(id int) (model.MenuRequest, error)
-	Save(item model.MenuItem, menuIngredients []model.MenuInventory) error
-	Update(item model.MenuItem, menuIngredients []model.MenuInventory) error
+	Save(menu model.MenuRequest) error
+	Update(menu model.MenuRequest) error
 	Delete(id int) error
 }
 
@@ -54,7 +54,8 @@ func (f *Menu) GetAll() ([]model.MenuRequest, error) {
 	for rows.Next() {
 		var id int
 		var menuName, description, inventoryName string
-		var price, quantity float64
+		var price model.Money
+		var quantity float64
 		var tagsString []string
 
 		if err := rows.Scan(&id, &menuName, &description, &quantity, &price, pq.Array(&tagsString), &inventoryName); err != nil {
@@ -81,8 +82,20 @@ func (f *Menu) GetAll() ([]model.MenuRequest, error) {
 		})
 	}
 
+	sizes, err := f.loadSizes(0)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiers, err := f.loadModifiers(0)
+	if err != nil {
+		return nil, err
+	}
+
 	var menuReq []model.MenuRequest
 	for _, value := range menuMap {
+		value.Sizes = sizes[value.Menu.ID]
+		value.Modifiers = modifiers[value.Menu.ID]
 		menuReq = append(menuReq, *value)
 	}
 
@@ -148,10 +161,24 @@ func (f *Menu) GetByID(id int) (model.MenuRequest, error) {
 		return model.MenuRequest{}, sql.ErrNoRows
 	}
 
+	sizes, err := f.loadSizes(id)
+	if err != nil {
+		return model.MenuRequest{}, err
+	}
+	menuItem.Sizes = sizes[id]
+
+	modifiers, err := f.loadModifiers(id)
+	if err != nil {
+		return model.MenuRequest{}, err
+	}
+	menuItem.Modifiers = modifiers[id]
+
 	return menuItem, nil
 }
 
-func (f *Menu) Save(item model.MenuItem, menuIngredients []model.MenuInventory) error {
+func (f *Menu) Save(menu model.MenuRequest) error {
+	item, menuIngredients := menu.Menu, menu.MenuIngredients
+
 	tx, err := f.db.Begin()
 	if err != nil {
 		return err
@@ -185,6 +212,16 @@ func (f *Menu) Save(item model.MenuItem, menuIngredients []model.MenuInventory)
 		}
 	}
 
+	if err = saveSizes(tx, menuItemID, menu.Sizes); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = saveModifiers(tx, menuItemID, menu.Modifiers); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	priceHistoryQuery := `INSERT INTO price_history (menu_item_id, new_price, changed_at)
 						  VALUES($1, $2, $3)`
 
@@ -197,14 +234,16 @@ func (f *Menu) Save(item model.MenuItem, menuIngredients []model.MenuInventory)
 	return tx.Commit()
 }
 
-func (f *Menu) Update(item model.MenuItem, menuIngredients []model.MenuInventory) error {
+func (f *Menu) Update(menu model.MenuRequest) error {
+	item, menuIngredients := menu.Menu, menu.MenuIngredients
+
 	tx, err := f.db.Begin()
 	if err != nil {
 		fmt.Println("HERE 1")
 		return err
 	}
 
-	var oldPrice float64
+	var oldPrice model.Money
 
 	oldPriceQuery := `SELECT price FROM menu_items
 				 	  WHERE menu_item_id = $1`
@@ -278,9 +317,172 @@ func (f *Menu) Update(item model.MenuItem, menuIngredients []model.MenuInventory
 		}
 	}
 
+	// sizes and modifiers left out of the request stay as they are
+	if menu.Sizes != nil {
+		if err = saveSizes(tx, item.ID, menu.Sizes); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if menu.Modifiers != nil {
+		if err = saveModifiers(tx, item.ID, menu.Modifiers); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
+// saveSizes replaces the size variants of a menu item.
+func saveSizes(tx *sql.Tx, menuItemID int, sizes []model.MenuItemSize) error {
+	if _, err := tx.Exec(`DELETE FROM menu_item_sizes WHERE menu_item_id = $1`, menuItemID); err != nil {
+		return err
+	}
+
+	for _, size := range sizes {
+		multiplier := size.IngredientMultiplier
+		if multiplier == 0 {
+			multiplier = 1
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO menu_item_sizes (menu_item_id, size, price, ingredient_multiplier)
+			VALUES ($1, $2, $3, $4)
+		`, menuItemID, size.Size, size.Price, multiplier)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSizes returns the size variants of one menu item, or of all menu items
+// when menuItemID is 0, keyed by menu item ID.
+func (f *Menu) loadSizes(menuItemID int) (map[int][]model.MenuItemSize, error) {
+	rows, err := f.db.Query(`
+		SELECT menu_item_id, size, price, ingredient_multiplier
+		FROM menu_item_sizes
+		WHERE $1 = 0 OR menu_item_id = $1
+		ORDER BY menu_item_id, size
+	`, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := make(map[int][]model.MenuItemSize)
+	for rows.Next() {
+		var itemID int
+		var size model.MenuItemSize
+		if err := rows.Scan(&itemID, &size.Size, &size.Price, &size.IngredientMultiplier); err != nil {
+			return nil, err
+		}
+		sizes[itemID] = append(sizes[itemID], size)
+	}
+	return sizes, rows.Err()
+}
+
+// saveModifiers makes the modifiers of a menu item match the given list.
+// Modifiers are matched by name so that existing ones keep their IDs.
+func saveModifiers(tx *sql.Tx, menuItemID int, modifiers []model.Modifier) error {
+	names := make([]string, 0, len(modifiers))
+
+	for _, modifier := range modifiers {
+		var modifierID int
+		err := tx.QueryRow(`
+			INSERT INTO menu_item_modifiers (menu_item_id, name, price_delta)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (menu_item_id, name) DO UPDATE SET price_delta = EXCLUDED.price_delta
+			RETURNING modifier_id
+		`, menuItemID, modifier.Name, modifier.PriceDelta).Scan(&modifierID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM modifier_ingredients WHERE modifier_id = $1`, modifierID); err != nil {
+			return err
+		}
+
+		for _, ingredient := range modifier.Ingredients {
+			var inventoryID int
+			err := tx.QueryRow(`SELECT inventory_id FROM inventory WHERE name = $1`, ingredient.Inventory.Name).Scan(&inventoryID)
+			if err != nil {
+				return fmt.Errorf("no such item in inventory: '%s'", ingredient.Inventory.Name)
+			}
+
+			_, err = tx.Exec(`
+				INSERT INTO modifier_ingredients (modifier_id, inventory_id, quantity)
+				VALUES ($1, $2, $3)
+			`, modifierID, inventoryID, ingredient.Quantity)
+			if err != nil {
+				return err
+			}
+		}
+
+		names = append(names, modifier.Name)
+	}
+
+	_, err := tx.Exec(`
+		DELETE FROM menu_item_modifiers
+		WHERE menu_item_id = $1 AND NOT (name = ANY($2))
+	`, menuItemID, pq.Array(names))
+	return err
+}
+
+// loadModifiers returns the modifiers of one menu item, or of all menu items
+// when menuItemID is 0, keyed by menu item ID.
+func (f *Menu) loadModifiers(menuItemID int) (map[int][]model.Modifier, error) {
+	query := `
+		SELECT
+			m.menu_item_id,
+			m.modifier_id,
+			m.name,
+			m.price_delta,
+			inventory.name,
+			mi.quantity
+		FROM menu_item_modifiers m
+		LEFT JOIN modifier_ingredients mi ON mi.modifier_id = m.modifier_id
+		LEFT JOIN inventory ON inventory.inventory_id = mi.inventory_id
+		WHERE $1 = 0 OR m.menu_item_id = $1
+		ORDER BY m.menu_item_id, m.modifier_id, mi.id
+	`
+
+	rows, err := f.db.Query(query, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	modifiers := make(map[int][]model.Modifier)
+	for rows.Next() {
+		var itemID int
+		var modifier model.Modifier
+		var ingredientName sql.NullString
+		var quantity sql.NullFloat64
+
+		if err := rows.Scan(&itemID, &modifier.ID, &modifier.Name, &modifier.PriceDelta, &ingredientName, &quantity); err != nil {
+			return nil, err
+		}
+
+		list := modifiers[itemID]
+		if len(list) == 0 || list[len(list)-1].ID != modifier.ID {
+			modifier.Ingredients = []model.MenuInventory{}
+			list = append(list, modifier)
+		}
+		if ingredientName.Valid {
+			last := &list[len(list)-1]
+			last.Ingredients = append(last.Ingredients, model.MenuInventory{
+				Inventory: model.InventoryMenuRequest{Name: ingredientName.String},
+				Quantity:  quantity.Float64,
+			})
+		}
+		modifiers[itemID] = list
+	}
+	return modifiers, rows.Err()
+}
+
 func (f *Menu) Delete(id int) error {
 	tx, err := f.db.Begin()
 	if err != nil {
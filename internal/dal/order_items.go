@@ -0,0 +1,349 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	model "frappuccino/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidModifier = errors.New("invalid_modifier")
+	ErrInvalidSize     = errors.New("invalid_size")
+)
+
+// orderLine is a requested order item resolved against the current menu.
+type orderLine struct {
+	MenuItemID     int
+	Name           string
+	Tags           []string
+	Size           string
+	UnitPrice      model.Money
+	Quantity       int
+	Customizations map[string]interface{}
+	Modifiers      []lineModifier
+	// Needs holds the ingredients used by the whole line, not a single portion.
+	Needs map[int]float64
+}
+
+type lineModifier struct {
+	ID         int
+	Name       string
+	PriceDelta model.Money
+}
+
+// resolveOrderLines looks up the requested menu items, sizes and modifiers and
+// prices them at the current menu prices.
+func resolveOrderLines(tx *sql.Tx, itemReq []model.OrderItemRequest) ([]orderLine, error) {
+	lines := make([]orderLine, 0, len(itemReq))
+
+	for _, item := range itemReq {
+		line := orderLine{
+			Name:           item.MenuItemID,
+			Size:           item.Size,
+			Quantity:       item.Quantity,
+			Customizations: item.Customizations,
+		}
+		if line.Size == "" {
+			line.Size = model.SizeMedium
+		}
+
+		err := tx.QueryRow(`SELECT menu_item_id, price, tags FROM menu_items WHERE name = $1`, item.MenuItemID).Scan(&line.MenuItemID, &line.UnitPrice, pq.Array(&line.Tags))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: menu item '%s' not found", ErrMenuItemNotFound, item.MenuItemID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		multiplier := 1.0
+		err = tx.QueryRow(`
+			SELECT price, ingredient_multiplier
+			FROM menu_item_sizes
+			WHERE menu_item_id = $1 AND size = $2
+		`, line.MenuItemID, line.Size).Scan(&line.UnitPrice, &multiplier)
+		if errors.Is(err, sql.ErrNoRows) && line.Size != model.SizeMedium {
+			return nil, fmt.Errorf("%w: '%s' is not sold in size %s", ErrInvalidSize, item.MenuItemID, line.Size)
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		modifierIDs := make([]int, 0, len(item.Modifiers))
+		for _, name := range item.Modifiers {
+			modifier := lineModifier{Name: name}
+			err := tx.QueryRow(`
+				SELECT modifier_id, price_delta
+				FROM menu_item_modifiers
+				WHERE menu_item_id = $1 AND name = $2
+			`, line.MenuItemID, name).Scan(&modifier.ID, &modifier.PriceDelta)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("%w: '%s' is not available for '%s'", ErrInvalidModifier, name, item.MenuItemID)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			line.UnitPrice += modifier.PriceDelta
+			line.Modifiers = append(line.Modifiers, modifier)
+			modifierIDs = append(modifierIDs, modifier.ID)
+		}
+
+		if line.UnitPrice <= 0 {
+			return nil, fmt.Errorf("%w: modifiers bring the price of '%s' to %s", ErrInvalidModifier, item.MenuItemID, line.UnitPrice)
+		}
+
+		portion, err := portionNeeds(tx, line.MenuItemID, multiplier, modifierIDs)
+		if err != nil {
+			return nil, err
+		}
+		line.Needs = scaleNeeds(portion, float64(line.Quantity))
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// portionNeeds returns the ingredients of one portion of a menu item. The
+// recipe is scaled by the size multiplier and the modifier quantities are then
+// added unscaled, so a negative quantity removes an ingredient; nothing goes
+// below zero.
+func portionNeeds(tx *sql.Tx, menuItemID int, multiplier float64, modifierIDs []int) (map[int]float64, error) {
+	needs := make(map[int]float64)
+
+	if err := addIngredientRows(tx, needs, `
+		SELECT inventory_id, quantity
+		FROM menu_item_ingredients
+		WHERE menu_item_id = $1
+	`, menuItemID); err != nil {
+		return nil, err
+	}
+	for inventoryID, qty := range needs {
+		needs[inventoryID] = qty * multiplier
+	}
+
+	for _, modifierID := range modifierIDs {
+		if err := addIngredientRows(tx, needs, `
+			SELECT inventory_id, quantity
+			FROM modifier_ingredients
+			WHERE modifier_id = $1
+		`, modifierID); err != nil {
+			return nil, err
+		}
+	}
+
+	for inventoryID, qty := range needs {
+		if qty <= 0 {
+			delete(needs, inventoryID)
+		}
+	}
+	return needs, nil
+}
+
+// addIngredientRows adds the (inventory_id, quantity) rows returned by query
+// to needs.
+func addIngredientRows(tx *sql.Tx, needs map[int]float64, query string, args ...interface{}) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var inventoryID int
+		var qty float64
+		if err := rows.Scan(&inventoryID, &qty); err != nil {
+			return err
+		}
+		needs[inventoryID] += qty
+	}
+	return rows.Err()
+}
+
+func scaleNeeds(needs map[int]float64, factor float64) map[int]float64 {
+	scaled := make(map[int]float64, len(needs))
+	for inventoryID, qty := range needs {
+		scaled[inventoryID] = qty * factor
+	}
+	return scaled
+}
+
+// linesTotals sums the ingredient needs and the price of order lines.
+func linesTotals(lines []orderLine) (map[int]float64, model.Money) {
+	needs := make(map[int]float64)
+	var total model.Money
+	for _, line := range lines {
+		for inventoryID, qty := range line.Needs {
+			needs[inventoryID] += qty
+		}
+		total += line.UnitPrice.Mul(line.Quantity)
+	}
+	return needs, total
+}
+
+// orderPricing is the price breakdown of an order.
+type orderPricing struct {
+	Subtotal  model.Money
+	Discount  model.Money
+	Tax       model.Money
+	Tip       model.Money
+	Total     model.Money
+	Discounts []model.OrderDiscount
+	Taxes     []model.OrderTax
+
+	LoyaltyID      string
+	PointsRedeemed int
+}
+
+// priceOrder prices order lines: promotions and then redeemed loyalty points
+// come off the subtotal, tax is charged on the discounted lines and the tip is
+// added last. Only exclusive tax raises the total.
+func priceOrder(tx *sql.Tx, lines []orderLine, order model.OrderRequest) (orderPricing, error) {
+	var pricing orderPricing
+	var err error
+	_, pricing.Subtotal = linesTotals(lines)
+	pricing.Tip = order.Tip
+	pricing.LoyaltyID = order.LoyaltyID
+
+	pricing.Discounts, pricing.Discount, err = applyPromotions(tx, lines, pricing.Subtotal, order.PromoCode)
+	if err != nil {
+		return orderPricing{}, err
+	}
+
+	pointsDiscount, points, err := redeemPoints(tx, order.LoyaltyID, order.RedeemPoints, pricing.Subtotal-pricing.Discount)
+	if err != nil {
+		return orderPricing{}, err
+	}
+	if points > 0 {
+		pricing.Discounts = append(pricing.Discounts, pointsDiscount)
+		pricing.Discount += pointsDiscount.Amount
+		pricing.PointsRedeemed = points
+	}
+
+	var exclusiveTax model.Money
+	pricing.Taxes, exclusiveTax, err = applyTaxes(tx, lines, pricing.Subtotal, pricing.Discount)
+	if err != nil {
+		return orderPricing{}, err
+	}
+	for _, tax := range pricing.Taxes {
+		pricing.Tax += tax.Amount
+	}
+
+	pricing.Total = pricing.Subtotal - pricing.Discount + exclusiveTax + pricing.Tip
+	return pricing, nil
+}
+
+// saveOrderPricing stores the discount and tax lines of an order and takes
+// the redeemed points off the loyalty account.
+func saveOrderPricing(tx *sql.Tx, orderID int, pricing orderPricing) error {
+	if err := saveOrderDiscounts(tx, orderID, pricing.Discounts); err != nil {
+		return err
+	}
+	if err := addLoyaltyEntry(tx, pricing.LoyaltyID, orderID, model.LoyaltyRedeem, -pricing.PointsRedeemed); err != nil {
+		return err
+	}
+	return saveOrderTaxes(tx, orderID, pricing.Taxes)
+}
+
+// storedItem is an order item as saved in order_items together with the
+// ingredients of one of its portions.
+type storedItem struct {
+	OrderItemID int
+	MenuItemID  int
+	Quantity    int
+	Portion     map[int]float64
+}
+
+// storedOrderItems loads the items of an order that still refer to a menu
+// item and works out the ingredients of one portion of each.
+func storedOrderItems(tx *sql.Tx, orderID int) ([]storedItem, error) {
+	rows, err := tx.Query(`
+		SELECT oi.order_item_id, oi.menu_item_id, oi.quantity, COALESCE(s.ingredient_multiplier, 1)
+		FROM order_items oi
+		LEFT JOIN menu_item_sizes s ON s.menu_item_id = oi.menu_item_id AND s.size = oi.size
+		WHERE oi.order_id = $1 AND oi.menu_item_id IS NOT NULL
+		ORDER BY oi.order_item_id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []storedItem
+	var multipliers []float64
+	for rows.Next() {
+		var item storedItem
+		var multiplier float64
+		if err := rows.Scan(&item.OrderItemID, &item.MenuItemID, &item.Quantity, &multiplier); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, item)
+		multipliers = append(multipliers, multiplier)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		var modifierIDs []int
+		modifierRows, err := tx.Query(`
+			SELECT modifier_id
+			FROM order_item_modifiers
+			WHERE order_item_id = $1 AND modifier_id IS NOT NULL
+		`, items[i].OrderItemID)
+		if err != nil {
+			return nil, err
+		}
+		for modifierRows.Next() {
+			var modifierID int
+			if err := modifierRows.Scan(&modifierID); err != nil {
+				modifierRows.Close()
+				return nil, err
+			}
+			modifierIDs = append(modifierIDs, modifierID)
+		}
+		modifierRows.Close()
+
+		if items[i].Portion, err = portionNeeds(tx, items[i].MenuItemID, multipliers[i], modifierIDs); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// insertOrderItems stores the resolved lines of an order together with the
+// modifiers chosen for each of them.
+func insertOrderItems(tx *sql.Tx, orderID int, lines []orderLine) error {
+	for _, line := range lines {
+		customizations, err := jsonbValue(line.Customizations)
+		if err != nil {
+			return err
+		}
+
+		var orderItemID int
+		err = tx.QueryRow(`
+			INSERT INTO order_items (menu_item_id, item_name, order_id, customizations, price_at_order_time, quantity, size)
+			VALUES($1, $2, $3, $4, $5, $6, $7)
+			RETURNING order_item_id
+		`, line.MenuItemID, line.Name, orderID, customizations, line.UnitPrice, line.Quantity, line.Size).Scan(&orderItemID)
+		if err != nil {
+			return err
+		}
+
+		for _, modifier := range line.Modifiers {
+			_, err = tx.Exec(`
+				INSERT INTO order_item_modifiers (order_item_id, modifier_id, name, price_delta)
+				VALUES($1, $2, $3, $4)
+			`, orderItemID, modifier.ID, modifier.Name, modifier.PriceDelta)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,25 +1,40 @@
 package dal
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
 	"strings"
 	"time"
 
 	model "frappuccino/models"
+
+	"github.com/lib/pq"
 )
 
 type OrderRepository interface {
-	Add(name string, itemReq []model.OrderItemRequest) (int, []model.InventoryUpdate, error)
-	GetAll() ([]model.OrderResponse, error)
-	GetByID(id int) (model.OrderResponse, error)
-	Update(name string, id int, itemReq []model.OrderItemRequest) error
+	Add(order model.OrderRequest) (model.PlacedOrder, error)
+	AddBatch(orders []model.OrderRequest) ([]model.PlacedOrder, []error, error)
+	PreviewBatch(orders []model.OrderRequest) ([]model.PlacedOrder, []error, error)
+	GetAll(filter model.OrderFilter) (model.OrderPage, error)
+	GetByID(id int, loc *time.Location) (model.OrderResponse, error)
+	GetByCustomer(customerID int, loc *time.Location) ([]model.OrderResponse, error)
+	ReorderRequest(id int) (model.OrderRequest, []model.UnavailableItem, error)
+	Update(id int, order model.OrderRequest) error
 	Delete(id int) error
-	UpdateStatus(id int, status string) error
-	NumberOfOrders(startDate, endDate interface{}) (model.NumberOfOrderedItemsResponse, error)
-	GetPriceMap(names []string) (map[string]float64, error)
+	UpdateStatus(id int, from []string, status string) error
+	Cancel(id int, from []string) error
+	History(id int, loc *time.Location) ([]model.OrderStatusHistory, error)
+	FlagUnfulfillable() (map[int]string, error)
+	DueScheduled(before time.Time) ([]int, error)
+	ReleaseScheduled(id int) error
+	NumberOfOrders(startDate, endDate interface{}, loc *time.Location) (model.NumberOfOrderedItemsResponse, error)
+	NumberOfOrdersBySize(startDate, endDate interface{}, loc *time.Location) (model.NumberOfOrderedItemsBySizeResponse, error)
 }
 
 type Order struct {
@@ -31,125 +46,331 @@ func NewOrderRepo(db *sql.DB) *Order {
 }
 
 var (
-	ErrNotEnoughStock   = errors.New("insufficient_inventory")
-	ErrMenuItemNotFound = errors.New("menu_item_not_found")
+	ErrNotEnoughStock    = errors.New("insufficient_inventory")
+	ErrMenuItemNotFound  = errors.New("menu_item_not_found")
+	ErrOrderNotFound     = errors.New("order_not_found")
+	ErrOrderNotActive    = errors.New("order_not_active")
+	ErrInvalidTransition = errors.New("invalid_status_transition")
+	ErrOrderConflict     = errors.New("order_conflict")
+	ErrOrderOverpaid     = errors.New("order_overpaid")
+	ErrOrderNotDeletable = errors.New("order_not_deletable")
 )
 
-func (o *Order) Add(name string, itemReq []model.OrderItemRequest) (int, []model.InventoryUpdate, error) {
+func (o *Order) Add(order model.OrderRequest) (model.PlacedOrder, error) {
 	tx, err := o.db.Begin()
 	if err != nil {
-		return 0, nil, err
+		return model.PlacedOrder{}, err
 	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-	ingredientNeeds := make(map[int]float64)
-	totalAmount := 0.0
+	var placed model.PlacedOrder
+	if placed, err = placeOrder(tx, order); err != nil {
+		return model.PlacedOrder{}, err
+	}
 
-	for _, item := range itemReq {
-		var price float64
-		err := tx.QueryRow(`SELECT price FROM menu_items WHERE name = $1`, item.MenuItemID).Scan(&price)
-		if err != nil {
+	if err = tx.Commit(); err != nil {
+		return model.PlacedOrder{}, err
+	}
+	return placed, nil
+}
+
+// batchAttempts is how many times a batch is tried when it loses a deadlock
+// or serialization conflict against concurrent orders.
+const batchAttempts = 3
+
+// AddBatch places orders in a single transaction. Every order runs under its
+// own savepoint, so a rejected order is undone without stopping the rest and
+// its error is returned at the same index as the order. The transaction is
+// committed only when no order was rejected; otherwise everything is rolled
+// back. A batch that loses a lock conflict is retried, and fails with
+// ErrOrderConflict once out of attempts.
+func (o *Order) AddBatch(orders []model.OrderRequest) ([]model.PlacedOrder, []error, error) {
+	for attempt := 1; ; attempt++ {
+		placed, errs, err := o.addBatch(orders)
+		conflict := isLockConflict(err) || slices.ContainsFunc(errs, isLockConflict)
+		if !conflict {
+			return placed, errs, err
+		}
+		if attempt == batchAttempts {
+			return nil, nil, fmt.Errorf("%w: batch lost a lock conflict %d times", ErrOrderConflict, attempt)
+		}
+	}
+}
+
+func (o *Order) addBatch(orders []model.OrderRequest) ([]model.PlacedOrder, []error, error) {
+	tx, err := o.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	commit := true
+	defer func() {
+		if err != nil || !commit {
 			tx.Rollback()
-			if errors.Is(err, sql.ErrNoRows) {
-				return 0, nil, fmt.Errorf("%w: menu item '%s' not found", ErrMenuItemNotFound, item.MenuItemID)
-			}
-			return 0, nil, err
 		}
-		totalAmount += price * float64(item.Quantity)
+	}()
+
+	if err = lockBatchIngredients(tx, orders); err != nil {
+		return nil, nil, err
+	}
+
+	placed := make([]model.PlacedOrder, len(orders))
+	errs := make([]error, len(orders))
+	for i, order := range orders {
+		if _, err = tx.Exec(`SAVEPOINT batch_order`); err != nil {
+			return nil, nil, err
+		}
 
-		rows, err := tx.Query(`
-			SELECT inventory_id, quantity
-			FROM menu_item_ingredients
-			JOIN menu_items ON menu_item_ingredients.menu_item_id = menu_items.menu_item_id
-			WHERE menu_items.name = $1
-		`, item.MenuItemID)
+		placed[i], errs[i] = placeOrder(tx, order)
+		if errs[i] != nil {
+			commit = false
+			_, err = tx.Exec(`ROLLBACK TO SAVEPOINT batch_order`)
+		} else {
+			_, err = tx.Exec(`RELEASE SAVEPOINT batch_order`)
+		}
 		if err != nil {
-			tx.Rollback()
-			return 0, nil, err
+			return nil, nil, err
 		}
-		defer rows.Close()
+	}
 
-		for rows.Next() {
-			var inventoryID int
-			var quantityPerPortion float64
-			if err := rows.Scan(&inventoryID, &quantityPerPortion); err != nil {
-				tx.Rollback()
-				return 0, nil, err
-			}
-			ingredientNeeds[inventoryID] += quantityPerPortion * float64(item.Quantity)
+	if commit {
+		err = tx.Commit()
+	}
+	return placed, errs, err
+}
+
+// PreviewBatch works out what AddBatch would place from plain reads. Nothing
+// is written and no row is locked, so checking a large batch does not hold up
+// live orders. Each order is checked against stock_level - reserved_level as
+// it is now, less what the orders before it in the batch would use. Usage
+// limits of promotions are checked against the uses counted so far.
+func (o *Order) PreviewBatch(orders []model.OrderRequest) ([]model.PlacedOrder, []error, error) {
+	tx, err := o.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	stock := make(map[int]*projectedStock)
+	placed := make([]model.PlacedOrder, len(orders))
+	errs := make([]error, len(orders))
+	for i, order := range orders {
+		placed[i], errs[i] = previewOrder(tx, order, stock)
+		if errs[i] != nil && !isPreviewRejection(errs[i]) {
+			return nil, nil, errs[i]
 		}
 	}
+	return placed, errs, nil
+}
+
+// projectedStock is what a dry run expects to be left of an ingredient.
+type projectedStock struct {
+	name string
+	left float64
+}
 
-	for inventoryID, neededQty := range ingredientNeeds {
-		var currentStock float64
-		err := tx.QueryRow(`SELECT stock_level FROM inventory WHERE inventory_id = $1`, inventoryID).Scan(&currentStock)
+// previewOrder prices an order and checks its ingredients against stock,
+// taking them off stock when the order fits.
+func previewOrder(tx *sql.Tx, order model.OrderRequest, stock map[int]*projectedStock) (model.PlacedOrder, error) {
+	lines, err := resolveOrderLines(tx, order.Orders)
+	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+	ingredientNeeds, _ := linesTotals(lines)
+
+	pricing, err := priceOrder(tx, lines, order)
+	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+
+	if _, err := resolveCustomerName(tx, order.CustomerID, order.CustomerName); err != nil {
+		return model.PlacedOrder{}, err
+	}
+
+	ids := slices.Sorted(maps.Keys(ingredientNeeds))
+	for _, inventoryID := range ids {
+		if _, ok := stock[inventoryID]; ok {
+			continue
+		}
+		projected := &projectedStock{}
+		err := tx.QueryRow(`SELECT name, stock_level - reserved_level FROM inventory WHERE inventory_id = $1`, inventoryID).
+			Scan(&projected.name, &projected.left)
 		if err != nil {
-			tx.Rollback()
-			return 0, nil, err
+			return model.PlacedOrder{}, err
 		}
-		if currentStock < neededQty {
-			tx.Rollback()
-			return 0, nil, fmt.Errorf("%w: not enough stock for ingredient %d: need %.2f, have %.2f", ErrNotEnoughStock, inventoryID, neededQty, currentStock)
+		stock[inventoryID] = projected
+	}
+	for _, inventoryID := range ids {
+		qty := math.Round(ingredientNeeds[inventoryID]*100) / 100
+		if left := stock[inventoryID].left; qty > 0 && left < qty {
+			return model.PlacedOrder{}, fmt.Errorf("%w: not enough stock for ingredient %d: need %.2f, have %.2f",
+				ErrNotEnoughStock, inventoryID, qty, left)
 		}
 	}
 
-	for inventoryID, usedQty := range ingredientNeeds {
-		_, err := tx.Exec(`UPDATE inventory SET stock_level = stock_level - $1 WHERE inventory_id = $2`, usedQty, inventoryID)
+	updates := make([]model.InventoryUpdate, 0, len(ids))
+	for _, inventoryID := range ids {
+		projected := stock[inventoryID]
+		if qty := math.Round(ingredientNeeds[inventoryID]*100) / 100; qty > 0 {
+			projected.left -= qty
+		}
+		updates = append(updates, model.InventoryUpdate{
+			IngredientID: inventoryID,
+			Name:         projected.name,
+			QuantityUsed: ingredientNeeds[inventoryID],
+			Remaining:    projected.left,
+		})
+	}
+
+	status := model.StatusPending
+	if order.PickupAt != nil {
+		status = model.StatusScheduled
+	}
+	return model.PlacedOrder{
+		Status:           status,
+		PickupAt:         order.PickupAt,
+		Subtotal:         pricing.Subtotal,
+		Discount:         pricing.Discount,
+		Tax:              pricing.Tax,
+		Tip:              pricing.Tip,
+		Total:            pricing.Total,
+		Discounts:        pricing.Discounts,
+		Taxes:            pricing.Taxes,
+		PointsRedeemed:   pricing.PointsRedeemed,
+		InventoryUpdates: updates,
+	}, nil
+}
+
+// isPreviewRejection reports whether a dry run rejects the order itself, as
+// opposed to failing to read the database.
+func isPreviewRejection(err error) bool {
+	return isOrderRequestError(err) || errors.Is(err, ErrNotEnoughStock) || errors.Is(err, ErrInvalidPromoCode) ||
+		errors.Is(err, ErrCustomerNotFound) || errors.Is(err, ErrNotEnoughPoints)
+}
+
+// lockBatchIngredients locks the inventory rows every order of a batch uses,
+// in id order, before any is reserved. Each order on its own reserves in id
+// order, but one after another they would not, and two batches could then
+// deadlock on each other. Orders that can not be resolved are skipped here;
+// placing them reports the error.
+func lockBatchIngredients(tx *sql.Tx, orders []model.OrderRequest) error {
+	ids := make(map[int]struct{})
+	for _, order := range orders {
+		lines, err := resolveOrderLines(tx, order.Orders)
 		if err != nil {
-			tx.Rollback()
-			return 0, nil, err
+			if isOrderRequestError(err) {
+				continue
+			}
+			return err
 		}
+		needs, _ := linesTotals(lines)
+		for inventoryID := range needs {
+			ids[inventoryID] = struct{}{}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT inventory_id FROM inventory
+		WHERE inventory_id = ANY($1)
+		ORDER BY inventory_id
+		FOR UPDATE
+	`, pq.Array(slices.Sorted(maps.Keys(ids))))
+	if err != nil {
+		return err
+	}
+	rows.Close()
+	return rows.Err()
+}
+
+// isOrderRequestError reports whether resolving an order failed because of
+// the request rather than the database.
+func isOrderRequestError(err error) bool {
+	return errors.Is(err, ErrMenuItemNotFound) || errors.Is(err, ErrInvalidSize) || errors.Is(err, ErrInvalidModifier)
+}
+
+// isLockConflict reports whether PostgreSQL aborted a statement to break a
+// deadlock or a serialization conflict, in which case it can be retried.
+func isLockConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40P01" || pqErr.Code == "40001")
+}
+
+// placeOrder prices an order, reserves its ingredients and stores it within tx.
+func placeOrder(tx *sql.Tx, order model.OrderRequest) (model.PlacedOrder, error) {
+	lines, err := resolveOrderLines(tx, order.Orders)
+	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+	ingredientNeeds, _ := linesTotals(lines)
+
+	if order.RedeemPoints > 0 {
+		if err := lockLoyaltyAccount(tx, order.LoyaltyID); err != nil {
+			return model.PlacedOrder{}, err
+		}
+	}
+
+	pricing, err := priceOrder(tx, lines, order)
+	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+
+	instructions, err := jsonbValue(order.SpecialInstructions)
+	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+
+	customerName, err := resolveCustomerName(tx, order.CustomerID, order.CustomerName)
+	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+
+	status := model.StatusPending
+	if order.PickupAt != nil {
+		status = model.StatusScheduled
 	}
 
 	var orderID int
 	err = tx.QueryRow(`
-		INSERT INTO orders(customer_name, status, total_amount)
-		VALUES($1, 'active', $2)
+		INSERT INTO orders(customer_id, customer_name, loyalty_id, status, subtotal, discount_amount, tax_amount, tip_amount, total_amount,
+			special_instructions, pickup_at)
+		VALUES(NULLIF($1, 0), $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING order_id
-	`, name, totalAmount).Scan(&orderID)
+	`, order.CustomerID, customerName, order.LoyaltyID, status, pricing.Subtotal, pricing.Discount, pricing.Tax,
+		pricing.Tip, pricing.Total, instructions, order.PickupAt).Scan(&orderID)
 	if err != nil {
-		tx.Rollback()
-		return 0, nil, err
+		return model.PlacedOrder{}, err
 	}
 
-	for _, item := range itemReq {
-		var price float64
-		err := tx.QueryRow(`SELECT price FROM menu_items WHERE name = $1`, item.MenuItemID).Scan(&price)
-		if err != nil {
-			tx.Rollback()
-			return 0, nil, err
-		}
+	if err := saveOrderPricing(tx, orderID, pricing); err != nil {
+		return model.PlacedOrder{}, err
+	}
 
-		var menuItemId int
-		err = tx.QueryRow(`SELECT menu_item_id FROM menu_items WHERE name = $1`, item.MenuItemID).Scan(&menuItemId)
-		if err != nil {
-			tx.Rollback()
-			return 0, nil, err
-		}
+	if err := reserveStock(tx, orderID, ingredientNeeds); err != nil {
+		return model.PlacedOrder{}, err
+	}
 
-		_, err = tx.Exec(`
-			INSERT INTO order_items (menu_item_id, order_id, customizations, price_at_order_time, quantity)
-			VALUES($1, $2, '{}', $3, $4)
-		`, menuItemId, orderID, price, item.Quantity)
-		if err != nil {
-			tx.Rollback()
-			return 0, nil, err
-		}
+	if err := insertOrderItems(tx, orderID, lines); err != nil {
+		return model.PlacedOrder{}, err
 	}
 
-	_, err = tx.Exec(`INSERT INTO order_status_history (order_id, status) VALUES($1, 'active')`, orderID)
+	_, err = tx.Exec(`INSERT INTO order_status_history (order_id, status) VALUES($1, $2)`, orderID, status)
 	if err != nil {
-		tx.Rollback()
-		return 0, nil, err
+		return model.PlacedOrder{}, err
 	}
 
 	var updates []model.InventoryUpdate
 	for inventoryID, usedQty := range ingredientNeeds {
 		var name string
 		var remaining float64
-		err := tx.QueryRow(`SELECT name, stock_level FROM inventory WHERE inventory_id = $1`, inventoryID).Scan(&name, &remaining)
+		err := tx.QueryRow(`SELECT name, stock_level - reserved_level FROM inventory WHERE inventory_id = $1`, inventoryID).Scan(&name, &remaining)
 		if err != nil {
-			tx.Rollback()
-			return 0, nil, err
+			return model.PlacedOrder{}, err
 		}
 		updates = append(updates, model.InventoryUpdate{
 			IngredientID: inventoryID,
@@ -159,226 +380,480 @@ func (o *Order) Add(name string, itemReq []model.OrderItemRequest) (int, []model
 		})
 	}
 
-	if err := tx.Commit(); err != nil {
-		return 0, nil, err
-	}
-
-	return orderID, updates, nil
+	return model.PlacedOrder{
+		OrderID:          orderID,
+		Status:           status,
+		PickupAt:         order.PickupAt,
+		Subtotal:         pricing.Subtotal,
+		Discount:         pricing.Discount,
+		Tax:              pricing.Tax,
+		Tip:              pricing.Tip,
+		Total:            pricing.Total,
+		Discounts:        pricing.Discounts,
+		Taxes:            pricing.Taxes,
+		PointsRedeemed:   pricing.PointsRedeemed,
+		InventoryUpdates: updates,
+	}, nil
 }
 
-func (o *Order) GetAll() ([]model.OrderResponse, error) {
-	query := `
-			SELECT 
+// orderResponseQuery selects orders with their items, discounts and taxes.
+// %s is replaced with an optional WHERE clause.
+const orderResponseQuery = `
+	SELECT
 		o.order_id,
+		COALESCE(o.customer_id, 0),
 		o.customer_name,
+		COALESCE(o.loyalty_id, ''),
 		o.status,
 		o.order_date AS created_at,
+		o.special_instructions,
+		o.pickup_at,
+		COALESCE(o.fulfillment_issue, ''),
+		o.subtotal,
+		o.discount_amount,
+		o.total_amount,
+		(
+			SELECT json_agg(json_build_object(
+				'promotion_id', od.promotion_id,
+				'name', od.name,
+				'code', od.code,
+				'amount', od.amount
+			) ORDER BY od.id)
+			FROM order_discounts od
+			WHERE od.order_id = o.order_id
+		) AS discounts,
+		o.tax_amount,
+		o.tip_amount,
+		(
+			SELECT json_agg(json_build_object(
+				'tax_rate_id', ot.tax_rate_id,
+				'name', ot.name,
+				'rate', ot.rate,
+				'inclusive', ot.inclusive,
+				'amount', ot.amount
+			) ORDER BY ot.id)
+			FROM order_taxes ot
+			WHERE ot.order_id = o.order_id
+		) AS taxes,
 		json_agg(json_build_object(
-			'product_id', mi.name,
-			'quantity', oi.quantity
+			'order_item_id', oi.order_item_id,
+			'product_id', oi.item_name,
+			'quantity', oi.quantity,
+			'size', oi.size,
+			'customizations', oi.customizations,
+			'modifiers', (
+				SELECT json_agg(oim.name ORDER BY oim.id)
+				FROM order_item_modifiers oim
+				WHERE oim.order_item_id = oi.order_item_id
+			)
 		) ORDER BY oi.order_item_id) AS items
-		FROM orders o
-		JOIN order_items oi ON o.order_id = oi.order_id
-		JOIN menu_items mi ON oi.menu_item_id = mi.menu_item_id
-		GROUP BY o.order_id, o.customer_name, o.status, o.order_date
-		ORDER BY o.order_id;
-	`
+	FROM orders o
+	JOIN order_items oi ON o.order_id = oi.order_id
+	%s
+	GROUP BY o.order_id
+	ORDER BY o.order_id`
+
+// orderSortColumns maps OrderFilter.SortBy to the column orders are sorted by.
+var orderSortColumns = map[string]string{
+	"date":  "o.order_date",
+	"total": "o.total_amount",
+	"id":    "o.order_id",
+}
 
-	rows, err := o.db.Query(query)
-	if err != nil {
-		return []model.OrderResponse{}, err
+// GetAll returns one page of the orders matching the filter. The page is
+// picked on the orders table alone and only its orders are loaded with their
+// items, so the cost does not grow with the number of orders.
+func (o *Order) GetAll(filter model.OrderFilter) (model.OrderPage, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
 	}
-	defer rows.Close()
 
-	var orders []model.OrderResponse
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "o.status::TEXT = ANY("+arg(pq.Array(filter.Statuses))+")")
+	}
+	if filter.CustomerName != "" {
+		conditions = append(conditions, "o.customer_name ILIKE '%' || "+arg(likeEscaper.Replace(filter.CustomerName))+" || '%'")
+	}
+	if filter.StartDate != "" || filter.EndDate != "" {
+		tz := arg(filter.Location.String())
+		if filter.StartDate != "" {
+			conditions = append(conditions, "o.order_date >= "+arg(filter.StartDate)+"::DATE::TIMESTAMP AT TIME ZONE "+tz+"::TEXT")
+		}
+		if filter.EndDate != "" {
+			conditions = append(conditions, "o.order_date < ("+arg(filter.EndDate)+"::DATE + 1)::TIMESTAMP AT TIME ZONE "+tz+"::TEXT")
+		}
+	}
+	if filter.MinTotal != nil {
+		conditions = append(conditions, "o.total_amount >= "+arg(*filter.MinTotal))
+	}
+	if filter.MaxTotal != nil {
+		conditions = append(conditions, "o.total_amount <= "+arg(*filter.MaxTotal))
+	}
+	if filter.MenuItem != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM order_items f
+			WHERE f.order_id = o.order_id AND LOWER(f.item_name) = LOWER(`+arg(filter.MenuItem)+`)
+		)`)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := o.db.QueryRow(`SELECT COUNT(*) FROM orders o `+where, args...).Scan(&total); err != nil {
+		return model.OrderPage{}, err
+	}
+
+	column, ok := orderSortColumns[filter.SortBy]
+	if !ok {
+		column = orderSortColumns["date"]
+	}
+	direction := "DESC"
+	if filter.Order == "asc" {
+		direction = "ASC"
+	}
+	offset := (filter.Page - 1) * filter.PageSize
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := fmt.Sprintf(`SELECT o.order_id FROM orders o %s ORDER BY %s %s, o.order_id %s LIMIT %s OFFSET %s`,
+		where, column, direction, direction, arg(filter.PageSize), arg(offset))
+	rows, err := o.db.Query(query, args...)
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+	var ids []int
 	for rows.Next() {
-		var order model.OrderResponse
-		var itemsRow []byte
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return model.OrderPage{}, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return model.OrderPage{}, err
+	}
 
-		if err := rows.Scan(&order.OrderID, &order.CustomerName, &order.Status, &order.CreatedAt, &itemsRow); err != nil {
-			return []model.OrderResponse{}, err
+	data := []model.OrderResponse{}
+	if len(ids) > 0 {
+		orders, err := o.queryOrders(filter.Location, "WHERE o.order_id = ANY($1)", pq.Array(ids))
+		if err != nil {
+			return model.OrderPage{}, err
+		}
+		byID := make(map[int]model.OrderResponse, len(orders))
+		for _, order := range orders {
+			byID[order.OrderID] = order
+		}
+		for _, id := range ids {
+			if order, ok := byID[id]; ok {
+				data = append(data, order)
+			}
 		}
+	}
 
-		loc, _ := time.LoadLocation("Asia/Almaty")
-		order.CreatedAt = order.CreatedAt.In(loc)
+	totalPages := (total + filter.PageSize - 1) / filter.PageSize
+	return model.OrderPage{
+		CurrentPage: filter.Page,
+		HasNextPage: filter.Page < totalPages,
+		PageSize:    filter.PageSize,
+		TotalPages:  totalPages,
+		TotalOrders: total,
+		Data:        data,
+	}, nil
+}
 
-		if err := json.Unmarshal(itemsRow, &order.Items); err != nil {
-			return []model.OrderResponse{}, err
-		}
-		orders = append(orders, order)
+// likeEscaper escapes the LIKE wildcards in a value matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (o *Order) GetByID(id int, loc *time.Location) (model.OrderResponse, error) {
+	orders, err := o.queryOrders(loc, "WHERE o.order_id = $1", id)
+	if err != nil {
+		return model.OrderResponse{}, err
+	}
+	if len(orders) == 0 {
+		return model.OrderResponse{}, fmt.Errorf("%w: order with id %d not found", ErrOrderNotFound, id)
+	}
+	return orders[0], nil
+}
+
+// GetByCustomer returns the orders linked to a customer profile.
+func (o *Order) GetByCustomer(customerID int, loc *time.Location) ([]model.OrderResponse, error) {
+	orders, err := o.queryOrders(loc, "WHERE o.customer_id = $1", customerID)
+	if err != nil {
+		return nil, err
+	}
+	if orders == nil {
+		orders = []model.OrderResponse{}
 	}
 	return orders, nil
 }
 
-func (o *Order) GetByID(id int) (model.OrderResponse, error) {
-	query := `
-		SELECT 
-		o.order_id,
-		o.customer_name,
-		o.status,
-		o.order_date AS created_at,
-		json_agg(json_build_object(
-			'product_id', mi.name,
-			'quantity', oi.quantity
-		) ORDER BY oi.order_item_id) AS items
-		FROM orders o
-		JOIN order_items oi ON o.order_id = oi.order_id
-		JOIN menu_items mi ON oi.menu_item_id = mi.menu_item_id
-		WHERE o.order_id = $1
-		GROUP BY o.order_id, o.customer_name, o.status, o.order_date
-		ORDER BY o.order_id;
-	`
+// ReorderRequest builds a request for a new order with the same customer,
+// instructions and items as order id. Items whose menu item has since been
+// removed, or whose size or modifiers are no longer offered, are left out and
+// returned with the reason.
+func (o *Order) ReorderRequest(id int) (model.OrderRequest, []model.UnavailableItem, error) {
+	var request model.OrderRequest
+	var instructions []byte
+	err := o.db.QueryRow(`
+		SELECT COALESCE(customer_id, 0), customer_name, COALESCE(loyalty_id, ''), special_instructions
+		FROM orders
+		WHERE order_id = $1
+	`, id).Scan(&request.CustomerID, &request.CustomerName, &request.LoyaltyID, &instructions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.OrderRequest{}, nil, fmt.Errorf("%w: order with id %d not found", ErrOrderNotFound, id)
+	}
+	if err != nil {
+		return model.OrderRequest{}, nil, err
+	}
+	if instructions != nil {
+		if err := json.Unmarshal(instructions, &request.SpecialInstructions); err != nil {
+			return model.OrderRequest{}, nil, err
+		}
+	}
 
-	rows, err := o.db.Query(query, id)
+	rows, err := o.db.Query(`
+		SELECT
+			oi.item_name,
+			mi.name,
+			oi.quantity,
+			oi.size,
+			oi.customizations,
+			(
+				SELECT array_agg(oim.name ORDER BY oim.id)
+				FROM order_item_modifiers oim
+				WHERE oim.order_item_id = oi.order_item_id
+			)
+		FROM order_items oi
+		LEFT JOIN menu_items mi ON mi.menu_item_id = oi.menu_item_id
+		WHERE oi.order_id = $1
+		ORDER BY oi.order_item_id
+	`, id)
 	if err != nil {
-		return model.OrderResponse{}, err
+		return model.OrderRequest{}, nil, err
 	}
 	defer rows.Close()
 
-	var order model.OrderResponse
-	var found bool
+	var items []model.OrderItemRequest
+	var unavailable []model.UnavailableItem
+	for rows.Next() {
+		var item model.OrderItemRequest
+		var itemName string
+		var menuName sql.NullString
+		var customizations []byte
+		if err := rows.Scan(&itemName, &menuName, &item.Quantity, &item.Size, &customizations, pq.Array(&item.Modifiers)); err != nil {
+			return model.OrderRequest{}, nil, err
+		}
+		if !menuName.Valid {
+			unavailable = append(unavailable, model.UnavailableItem{Name: itemName, Reason: model.UnavailableMenuItem})
+			continue
+		}
+		if customizations != nil {
+			if err := json.Unmarshal(customizations, &item.Customizations); err != nil {
+				return model.OrderRequest{}, nil, err
+			}
+		}
+		item.MenuItemID = menuName.String
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return model.OrderRequest{}, nil, err
+	}
+	rows.Close()
 
+	// check every item the way placing the order will, so that one size or
+	// modifier gone from the menu does not fail the whole reorder
+	tx, err := o.db.Begin()
+	if err != nil {
+		return model.OrderRequest{}, nil, err
+	}
+	defer tx.Rollback()
+
+	for _, item := range items {
+		_, err := resolveOrderLines(tx, []model.OrderItemRequest{item})
+		switch {
+		case err == nil:
+			request.Orders = append(request.Orders, item)
+		case errors.Is(err, ErrInvalidSize):
+			unavailable = append(unavailable, model.UnavailableItem{Name: item.MenuItemID, Reason: model.UnavailableSize, Detail: err.Error()})
+		case errors.Is(err, ErrInvalidModifier):
+			unavailable = append(unavailable, model.UnavailableItem{Name: item.MenuItemID, Reason: model.UnavailableModifier, Detail: err.Error()})
+		case errors.Is(err, ErrMenuItemNotFound):
+			unavailable = append(unavailable, model.UnavailableItem{Name: item.MenuItemID, Reason: model.UnavailableMenuItem})
+		default:
+			return model.OrderRequest{}, nil, err
+		}
+	}
+	return request, unavailable, nil
+}
+
+// queryOrders loads the orders matching where, with their times in loc.
+func (o *Order) queryOrders(loc *time.Location, where string, args ...interface{}) ([]model.OrderResponse, error) {
+	rows, err := o.db.Query(fmt.Sprintf(orderResponseQuery, where), args...)
+	if err != nil {
+		return []model.OrderResponse{}, err
+	}
+	defer rows.Close()
+
+	var orders []model.OrderResponse
 	for rows.Next() {
-		found = true
-		var itemsRow []byte
-		if err := rows.Scan(&order.OrderID, &order.CustomerName, &order.Status, &order.CreatedAt, &itemsRow); err != nil {
-			return model.OrderResponse{}, err
+		var order model.OrderResponse
+		var instructionsRow, discountsRow, taxesRow, itemsRow []byte
+
+		if err := rows.Scan(&order.OrderID, &order.CustomerID, &order.CustomerName, &order.LoyaltyID, &order.Status, &order.CreatedAt,
+			&instructionsRow, &order.PickupAt, &order.FulfillmentIssue, &order.Subtotal, &order.Discount, &order.Total, &discountsRow,
+			&order.Tax, &order.Tip, &taxesRow, &itemsRow); err != nil {
+			return []model.OrderResponse{}, err
+		}
+
+		if instructionsRow != nil {
+			if err := json.Unmarshal(instructionsRow, &order.SpecialInstructions); err != nil {
+				return []model.OrderResponse{}, err
+			}
 		}
 
-		loc, _ := time.LoadLocation("Asia/Almaty")
 		order.CreatedAt = order.CreatedAt.In(loc)
+		if order.PickupAt != nil {
+			pickupAt := order.PickupAt.In(loc)
+			order.PickupAt = &pickupAt
+		}
 
-		if err := json.Unmarshal(itemsRow, &order.Items); err != nil {
-			return model.OrderResponse{}, err
+		if discountsRow != nil {
+			if err := json.Unmarshal(discountsRow, &order.Discounts); err != nil {
+				return []model.OrderResponse{}, err
+			}
 		}
-	}
 
-	if !found {
-		return model.OrderResponse{}, fmt.Errorf("order with id %d not found", id)
-	}
+		if taxesRow != nil {
+			if err := json.Unmarshal(taxesRow, &order.Taxes); err != nil {
+				return []model.OrderResponse{}, err
+			}
+		}
 
-	return order, nil
+		if err := json.Unmarshal(itemsRow, &order.Items); err != nil {
+			return []model.OrderResponse{}, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
 }
 
-func (o *Order) Update(name string, id int, itemReq []model.OrderItemRequest) error {
+// Update replaces the items of a pending or scheduled order and the stock
+// reserved for them. A scheduled order may also be moved to another pickup
+// time. The original order date is kept. An edit that would bring the total
+// below what has already been paid is refused, as there is no way to give the
+// difference back.
+func (o *Order) Update(id int, order model.OrderRequest) error {
 	tx, err := o.db.Begin()
 	if err != nil {
 		return err
 	}
-
 	defer func() {
 		if err != nil {
 			tx.Rollback()
-		} else {
-			tx.Commit()
 		}
 	}()
 
-	ingredientNeeds := make(map[int]float64)
-	totalAmount := 0.0
+	var status string
+	if status, err = lockOrder(tx, id); err != nil {
+		return err
+	}
+	if status != model.StatusPending && status != model.StatusScheduled {
+		err = fmt.Errorf("%w: order %d is %s", ErrOrderNotActive, id, status)
+		return err
+	}
+	if status == model.StatusPending && order.PickupAt != nil {
+		err = fmt.Errorf("%w: order %d is already in the queue and can not be scheduled", ErrOrderNotActive, id)
+		return err
+	}
 
-	for _, item := range itemReq {
-		var price float64
-		if err := tx.QueryRow(`SELECT price FROM menu_items WHERE name = $1`, item.MenuItemID).Scan(&price); err != nil {
-			return err
-		}
-		totalAmount += price * float64(item.Quantity)
+	var lines []orderLine
+	if lines, err = resolveOrderLines(tx, order.Orders); err != nil {
+		return err
+	}
+	newNeeds, _ := linesTotals(lines)
 
-		rows, err := tx.Query(`
-			SELECT inventory_id, quantity
-			FROM menu_item_ingredients
-			JOIN menu_items ON menu_item_ingredients.menu_item_id = menu_items.menu_item_id
-			WHERE menu_items.name = $1
-		`, item.MenuItemID)
-		if err != nil {
+	if err = releaseOrderDiscounts(tx, id); err != nil {
+		return err
+	}
+	if err = reverseRedeemedPoints(tx, id); err != nil {
+		return err
+	}
+	if order.RedeemPoints > 0 {
+		if err = lockLoyaltyAccount(tx, order.LoyaltyID); err != nil {
 			return err
 		}
+	}
+	var pricing orderPricing
+	if pricing, err = priceOrder(tx, lines, order); err != nil {
+		return err
+	}
+	var paid model.Money
+	if _, paid, err = orderBalance(tx, id); err != nil {
+		return err
+	}
+	if pricing.Total < paid {
+		err = fmt.Errorf("%w: order %d is paid %s, the new total would be %s", ErrOrderOverpaid, id, paid, pricing.Total)
+		return err
+	}
 
-		for rows.Next() {
-			var inventoryID int
-			var quantityPerPortion float64
-			if err := rows.Scan(&inventoryID, &quantityPerPortion); err != nil {
-				return err
-			}
-			ingredientNeeds[inventoryID] += quantityPerPortion * float64(item.Quantity)
-		}
-		rows.Close()
+	if err = saveOrderPricing(tx, id, pricing); err != nil {
+		return err
 	}
 
-	for inventoryID, neededQty := range ingredientNeeds {
-		var currentStock float64
-		if err := tx.QueryRow(`SELECT stock_level FROM inventory WHERE inventory_id = $1`, inventoryID).Scan(&currentStock); err != nil {
-			return err
-		}
-		if currentStock < neededQty {
-			return fmt.Errorf("not enough stock for ingredient %d: need %.2f, have %.2f", inventoryID, neededQty, currentStock)
-		}
+	if _, err = releaseReservations(tx, id); err != nil {
+		return err
+	}
+	if err = reserveStock(tx, id, newNeeds); err != nil {
+		return err
 	}
 
-	for inventoryID, usedQty := range ingredientNeeds {
-		_, err := tx.Exec(`UPDATE inventory SET stock_level = stock_level - $1 WHERE inventory_id = $2`, usedQty, inventoryID)
-		if err != nil {
-			return err
-		}
+	var instructions []byte
+	if instructions, err = jsonbValue(order.SpecialInstructions); err != nil {
+		return err
 	}
 
-	_, err = tx.Exec(`
-		UPDATE orders
-		SET customer_name = $1, status = 'active', total_amount = $2, order_date = NOW()
-		WHERE order_id = $3
-	`, name, totalAmount, id)
-	if err != nil {
+	var customerName string
+	if customerName, err = resolveCustomerName(tx, order.CustomerID, order.CustomerName); err != nil {
 		return err
 	}
 
 	_, err = tx.Exec(`
-		DELETE FROM order_items WHERE order_id = $1
-	`, id)
+		UPDATE orders
+		SET customer_id = NULLIF($1, 0), customer_name = $2, loyalty_id = NULLIF($3, ''), subtotal = $4, discount_amount = $5,
+			tax_amount = $6, tip_amount = $7, total_amount = $8, special_instructions = $9,
+			pickup_at = COALESCE($10, pickup_at), fulfillment_issue = NULL
+		WHERE order_id = $11
+	`, order.CustomerID, customerName, order.LoyaltyID, pricing.Subtotal, pricing.Discount, pricing.Tax, pricing.Tip,
+		pricing.Total, instructions, order.PickupAt, id)
 	if err != nil {
 		return err
 	}
 
-	for _, item := range itemReq {
-		var price float64
-		err := tx.QueryRow(`
-			SELECT price
-			FROM menu_items
-			WHERE name = $1
-		`, item.MenuItemID).Scan(&price)
-		if err != nil {
-			return err
-		}
-
-		var menuItemId int
-		err = tx.QueryRow(`
-			SELECT menu_item_id
-			FROM menu_items
-			WHERE name = $1
-		`, item.MenuItemID).Scan(&menuItemId)
-		if err != nil {
-			return err
-		}
-
-		_, err = tx.Exec(`
-			INSERT INTO order_items (menu_item_id, order_id, customizations, price_at_order_time, quantity)
-			VALUES($1, $2, '{}', $3, $4)
-		`, menuItemId, id, price, item.Quantity)
-		if err != nil {
-			return err
-		}
+	if _, err = tx.Exec(`DELETE FROM order_items WHERE order_id = $1`, id); err != nil {
+		return err
 	}
 
-	_, err = tx.Exec(`
-		INSERT INTO order_status_history (order_id, status)
-		VALUES($1, 'active')
-	`, id)
-	if err != nil {
+	if err = insertOrderItems(tx, id, lines); err != nil {
 		return err
 	}
 
-	return nil
+	err = tx.Commit()
+	return err
 }
 
+// Delete removes an order that has not been started yet: a pending or
+// scheduled one with nothing paid. Like Cancel it gives the reserved stock,
+// the promotion uses and the redeemed loyalty points back first. Other orders
+// are kept for the books and have to be cancelled or refunded instead.
 func (o *Order) Delete(id int) error {
 	tx, err := o.db.Begin()
 	if err != nil {
@@ -390,6 +865,36 @@ func (o *Order) Delete(id int) error {
 		}
 	}()
 
+	var status string
+	if status, err = lockOrder(tx, id); err != nil {
+		return err
+	}
+	if status != model.StatusPending && status != model.StatusScheduled {
+		err = fmt.Errorf("%w: order %d is %s", ErrOrderNotDeletable, id, status)
+		return err
+	}
+
+	var paid model.Money
+	if _, paid, err = orderBalance(tx, id); err != nil {
+		return err
+	}
+	if paid > 0 {
+		err = fmt.Errorf("%w: order %d has %s paid", ErrOrderHasPayments, id, paid)
+		return err
+	}
+
+	if _, err = releaseReservations(tx, id); err != nil {
+		return err
+	}
+
+	if err = releaseOrderDiscounts(tx, id); err != nil {
+		return err
+	}
+
+	if err = reverseRedeemedPoints(tx, id); err != nil {
+		return err
+	}
+
 	query := `DELETE FROM orders WHERE order_id = $1`
 	if _, err = tx.Exec(query, id); err != nil {
 		return err
@@ -398,7 +903,9 @@ func (o *Order) Delete(id int) error {
 	return tx.Commit()
 }
 
-func (o *Order) NumberOfOrders(startDate, endDate interface{}) (model.NumberOfOrderedItemsResponse, error) {
+// NumberOfOrders counts the ordered quantity of every menu item between two
+// days in loc, both included.
+func (o *Order) NumberOfOrders(startDate, endDate interface{}, loc *time.Location) (model.NumberOfOrderedItemsResponse, error) {
 	query := `
 		SELECT
 			mi.name,
@@ -407,13 +914,13 @@ func (o *Order) NumberOfOrders(startDate, endDate interface{}) (model.NumberOfOr
 		LEFT JOIN order_items oi ON mi.menu_item_id = oi.menu_item_id
 		LEFT JOIN orders o ON oi.order_id = o.order_id
 		WHERE
-			($1::DATE IS NULL OR o.order_date >= $1::DATE)
-			AND ($2::DATE IS NULL OR o.order_date <= $2::DATE)
+			($1::DATE IS NULL OR o.order_date >= $1::DATE::TIMESTAMP AT TIME ZONE $3::TEXT)
+			AND ($2::DATE IS NULL OR o.order_date < ($2::DATE + 1)::TIMESTAMP AT TIME ZONE $3::TEXT)
 		GROUP BY mi.name
 		ORDER BY mi.name;
 	`
 
-	rows, err := o.db.Query(query, startDate, endDate)
+	rows, err := o.db.Query(query, startDate, endDate, loc.String())
 	if err != nil {
 		return model.NumberOfOrderedItemsResponse{}, err
 	}
@@ -432,7 +939,54 @@ func (o *Order) NumberOfOrders(startDate, endDate interface{}) (model.NumberOfOr
 	return orderCount, nil
 }
 
-func (o *Order) UpdateStatus(id int, status string) error {
+// NumberOfOrdersBySize is NumberOfOrders with the quantities split by size.
+func (o *Order) NumberOfOrdersBySize(startDate, endDate interface{}, loc *time.Location) (model.NumberOfOrderedItemsBySizeResponse, error) {
+	query := `
+		SELECT
+			mi.name,
+			oi.size,
+			COALESCE(SUM(oi.quantity), 0) AS total_quantity
+		FROM menu_items mi
+		LEFT JOIN order_items oi ON mi.menu_item_id = oi.menu_item_id
+		LEFT JOIN orders o ON oi.order_id = o.order_id
+		WHERE
+			($1::DATE IS NULL OR o.order_date >= $1::DATE::TIMESTAMP AT TIME ZONE $3::TEXT)
+			AND ($2::DATE IS NULL OR o.order_date < ($2::DATE + 1)::TIMESTAMP AT TIME ZONE $3::TEXT)
+		GROUP BY mi.name, oi.size
+		ORDER BY mi.name, oi.size;
+	`
+
+	rows, err := o.db.Query(query, startDate, endDate, loc.String())
+	if err != nil {
+		return model.NumberOfOrderedItemsBySizeResponse{}, err
+	}
+	defer rows.Close()
+
+	orderCount := make(model.NumberOfOrderedItemsBySizeResponse)
+
+	for rows.Next() {
+		var name string
+		var size sql.NullString
+		var quantity int
+
+		if err := rows.Scan(&name, &size, &quantity); err != nil {
+			return model.NumberOfOrderedItemsBySizeResponse{}, err
+		}
+		if orderCount[name] == nil {
+			orderCount[name] = make(map[string]int)
+		}
+		if size.Valid {
+			orderCount[name][size.String] = quantity
+		}
+	}
+	return orderCount, rows.Err()
+}
+
+// UpdateStatus moves an order to status if its current status is one of from
+// and appends the change to order_status_history. An order can only be closed
+// once it is fully paid; closing it takes the reserved ingredients out of
+// inventory and earns loyalty points.
+func (o *Order) UpdateStatus(id int, from []string, status string) error {
 	tx, err := o.db.Begin()
 	if err != nil {
 		return err
@@ -443,59 +997,194 @@ func (o *Order) UpdateStatus(id int, status string) error {
 		}
 	}()
 
-	query := `
-		UPDATE order_status_history
-		SET status = $2, changed_at = $3
-		WHERE order_id = $1
-	`
-
-	if _, err = tx.Exec(query, id, status, time.Now()); err != nil {
+	if _, err = lockOrderForTransition(tx, id, from, status); err != nil {
 		return err
 	}
 
-	query = `
-		UPDATE orders
-		SET status = $2
-		WHERE order_id = $1
-	`
+	if status == model.StatusClosed {
+		var total, paid model.Money
+		if total, paid, err = orderBalance(tx, id); err != nil {
+			return err
+		}
+		if total-paid > 0 {
+			err = fmt.Errorf("%w: order %d has %s left to pay", ErrOrderNotPaid, id, total-paid)
+			return err
+		}
+		if err = consumeReservations(tx, id); err != nil {
+			return err
+		}
+		if err = earnPoints(tx, id); err != nil {
+			return err
+		}
+	}
 
-	if _, err = tx.Exec(query, id, status); err != nil {
+	if err = setOrderStatus(tx, id, status); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (o *Order) GetPriceMap(names []string) (map[string]float64, error) {
-	placeholders := make([]string, len(names))
-	args := make([]interface{}, len(names))
+// Cancel moves an order to cancelled if its current status is one of from and
+// releases the stock reserved for it. Redeemed loyalty points go back to the
+// account. An order that has been paid for, even in part, is not cancelled:
+// the money can only be given back by closing and refunding it.
+func (o *Order) Cancel(id int, from []string) error {
+	tx, err := o.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = lockOrderForTransition(tx, id, from, model.StatusCancelled); err != nil {
+		return err
+	}
 
-	for i, name := range names {
-		placeholders[i] = fmt.Sprintf("$%d", i+1)
-		args[i] = name
+	var paid model.Money
+	if _, paid, err = orderBalance(tx, id); err != nil {
+		return err
+	}
+	if paid > 0 {
+		err = fmt.Errorf("%w: order %d has %s paid, close and refund it instead", ErrOrderHasPayments, id, paid)
+		return err
 	}
 
-	query := fmt.Sprintf(`
-		SELECT name, price
-		FROM menu_items
-		WHERE name IN (%s)
-	`, strings.Join(placeholders, ","))
+	if _, err = releaseReservations(tx, id); err != nil {
+		return err
+	}
 
-	rows, err := o.db.Query(query, args...)
+	if err = releaseOrderDiscounts(tx, id); err != nil {
+		return err
+	}
+
+	if err = reverseRedeemedPoints(tx, id); err != nil {
+		return err
+	}
+
+	if err = setOrderStatus(tx, id, model.StatusCancelled); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// History returns the status changes of an order in the order they happened.
+func (o *Order) History(id int, loc *time.Location) ([]model.OrderStatusHistory, error) {
+	var exists bool
+	if err := o.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM orders WHERE order_id = $1)`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: order %d", ErrOrderNotFound, id)
+	}
+
+	rows, err := o.db.Query(`
+		SELECT id, order_id, status, changed_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY changed_at, id
+	`, id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	priceMap := make(map[string]float64)
+	history := []model.OrderStatusHistory{}
 	for rows.Next() {
-		var name string
-		var price float64
-		if err := rows.Scan(&name, &price); err != nil {
+		var entry model.OrderStatusHistory
+		if err := rows.Scan(&entry.ID, &entry.OrderID, &entry.Status, &entry.ChangedAt); err != nil {
 			return nil, err
 		}
-		priceMap[name] = price
+		entry.ChangedAt = entry.ChangedAt.In(loc)
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// lockOrder locks the order row for the rest of the transaction and returns
+// its current status.
+func lockOrder(tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM orders WHERE order_id = $1 FOR UPDATE`, id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: order %d", ErrOrderNotFound, id)
+	}
+	return status, err
+}
+
+// lockOrderForTransition locks the order and checks that it may move to
+// status from its current one.
+func lockOrderForTransition(tx *sql.Tx, id int, from []string, status string) (string, error) {
+	current, err := lockOrder(tx, id)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(from, current) {
+		return "", fmt.Errorf("%w: order %d can not move from %s to %s", ErrInvalidTransition, id, current, status)
+	}
+	return current, nil
+}
+
+// setOrderStatus updates the status of an order and appends the change to its
+// history.
+func setOrderStatus(tx *sql.Tx, id int, status string) error {
+	if _, err := tx.Exec(`UPDATE orders SET status = $2 WHERE order_id = $1`, id, status); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`INSERT INTO order_status_history (order_id, status) VALUES ($1, $2)`, id, status)
+	return err
+}
+
+// applyStockDelta takes positive quantities from inventory and returns
+// negative ones, writing one inventory transaction per changed ingredient.
+// Taking stock is a conditional UPDATE, so it fails with ErrNotEnoughStock
+// rather than racing another order below zero. Ingredients are handled in id
+// order so concurrent orders lock rows in the same order.
+func applyStockDelta(tx *sql.Tx, orderID int, delta map[int]float64) error {
+	for _, inventoryID := range slices.Sorted(maps.Keys(delta)) {
+		qty := math.Round(delta[inventoryID]*100) / 100
+		if qty == 0 {
+			continue
+		}
+
+		result, err := tx.Exec(`
+			UPDATE inventory
+			SET stock_level = stock_level - $1, last_updated = NOW()
+			WHERE inventory_id = $2 AND stock_level >= $1
+		`, qty, inventoryID)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return notEnoughStock(tx, inventoryID, qty, `stock_level`)
+		}
+
+		transactionType, amount := "consumption", qty
+		if qty < 0 {
+			transactionType, amount = "return", -qty
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO inventory_transactions (inventory_id, quantity, transaction_type, order_id)
+			VALUES ($1, $2, $3, $4)
+		`, inventoryID, amount, transactionType, orderID)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return priceMap, nil
+// jsonbValue encodes a map for a JSONB column, storing an empty object for a
+// nil map.
+func jsonbValue(value map[string]interface{}) ([]byte, error) {
+	if value == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(value)
 }
@@ -0,0 +1,184 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	model "frappuccino/models"
+)
+
+type PaymentRepository interface {
+	Add(orderID int, tenders []model.TenderRequest, loc *time.Location) (model.PaymentSummary, error)
+	GetByOrder(orderID int, loc *time.Location) (model.PaymentSummary, error)
+}
+
+type Payment struct {
+	db *sql.DB
+}
+
+func NewPaymentRepo(db *sql.DB) *Payment {
+	return &Payment{db: db}
+}
+
+var (
+	ErrOverpayment      = errors.New("payment_exceeds_balance")
+	ErrOrderNotPaid     = errors.New("order_not_fully_paid")
+	ErrOrderHasPayments = errors.New("order_has_payments")
+	ErrNotEnoughCash    = errors.New("insufficient_cash_tendered")
+)
+
+// Add records tenders against an order that is still open. Each tender may pay
+// at most the outstanding balance; for cash the surplus handed over is
+// returned as change. Gift card tenders are taken off the card balance.
+func (p *Payment) Add(orderID int, tenders []model.TenderRequest, loc *time.Location) (model.PaymentSummary, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return model.PaymentSummary{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var status string
+	if status, err = lockOrder(tx, orderID); err != nil {
+		return model.PaymentSummary{}, err
+	}
+	if status == model.StatusClosed || status == model.StatusCancelled {
+		err = fmt.Errorf("%w: order %d is %s", ErrOrderNotActive, orderID, status)
+		return model.PaymentSummary{}, err
+	}
+
+	var total, paid, changeDue model.Money
+	if total, paid, err = orderBalance(tx, orderID); err != nil {
+		return model.PaymentSummary{}, err
+	}
+
+	for _, tender := range tenders {
+		balance := total - paid
+		amount := tender.Amount
+		if amount == 0 {
+			amount = balance
+			if tender.Method == model.PaymentCash && tender.Tendered > 0 {
+				amount = min(tender.Tendered, balance)
+			}
+		}
+
+		if amount <= 0 || amount > balance {
+			err = fmt.Errorf("%w: order %d has %s left to pay", ErrOverpayment, orderID, balance)
+			return model.PaymentSummary{}, err
+		}
+
+		tendered := amount
+		if tender.Method == model.PaymentCash && tender.Tendered > 0 {
+			tendered = tender.Tendered
+			if tendered < amount {
+				err = fmt.Errorf("%w: %s tendered for %s", ErrNotEnoughCash, tendered, amount)
+				return model.PaymentSummary{}, err
+			}
+		}
+
+		var giftCardID int
+		if tender.Method == model.PaymentGiftCard {
+			if giftCardID, amount, err = redeemGiftCard(tx, tender.GiftCardCode, amount, tender.Amount == 0); err != nil {
+				return model.PaymentSummary{}, err
+			}
+			tendered = amount
+		}
+
+		var paymentID int
+		err = tx.QueryRow(`
+			INSERT INTO payments (order_id, method, gift_card_id, amount, tendered, change_due)
+			VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6)
+			RETURNING payment_id
+		`, orderID, tender.Method, giftCardID, amount, tendered, tendered-amount).Scan(&paymentID)
+		if err != nil {
+			return model.PaymentSummary{}, err
+		}
+
+		if giftCardID != 0 {
+			if err = addGiftCardTransaction(tx, giftCardID, model.GiftCardRedeem, -amount, paymentID); err != nil {
+				return model.PaymentSummary{}, err
+			}
+		}
+		paid += amount
+		changeDue += tendered - amount
+	}
+
+	var summary model.PaymentSummary
+	if summary, err = paymentSummary(tx, orderID, loc); err != nil {
+		return model.PaymentSummary{}, err
+	}
+	summary.ChangeDue = changeDue
+
+	if err = tx.Commit(); err != nil {
+		return model.PaymentSummary{}, err
+	}
+	return summary, nil
+}
+
+func (p *Payment) GetByOrder(orderID int, loc *time.Location) (model.PaymentSummary, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return model.PaymentSummary{}, err
+	}
+	defer tx.Rollback()
+
+	return paymentSummary(tx, orderID, loc)
+}
+
+// orderBalance returns the total of an order and how much has been paid so far.
+func orderBalance(tx *sql.Tx, orderID int) (model.Money, model.Money, error) {
+	var total, paid model.Money
+	err := tx.QueryRow(`
+		SELECT o.total_amount, COALESCE(SUM(p.amount), 0)
+		FROM orders o
+		LEFT JOIN payments p ON p.order_id = o.order_id
+		WHERE o.order_id = $1
+		GROUP BY o.order_id, o.total_amount
+	`, orderID).Scan(&total, &paid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, fmt.Errorf("%w: order %d", ErrOrderNotFound, orderID)
+	}
+	return total, paid, err
+}
+
+func paymentSummary(tx *sql.Tx, orderID int, loc *time.Location) (model.PaymentSummary, error) {
+	total, paid, err := orderBalance(tx, orderID)
+	if err != nil {
+		return model.PaymentSummary{}, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT p.payment_id, p.order_id, p.method, COALESCE(g.code, ''), p.amount, p.tendered, p.change_due, p.paid_at
+		FROM payments p
+		LEFT JOIN gift_cards g ON g.gift_card_id = p.gift_card_id
+		WHERE p.order_id = $1
+		ORDER BY p.paid_at, p.payment_id
+	`, orderID)
+	if err != nil {
+		return model.PaymentSummary{}, err
+	}
+	defer rows.Close()
+
+	summary := model.PaymentSummary{
+		OrderID:  orderID,
+		Total:    total,
+		Paid:     paid,
+		Balance:  total - paid,
+		Payments: []model.Payment{},
+	}
+	for rows.Next() {
+		var payment model.Payment
+		if err := rows.Scan(&payment.PaymentID, &payment.OrderID, &payment.Method, &payment.GiftCardCode, &payment.Amount,
+			&payment.Tendered, &payment.ChangeDue, &payment.PaidAt); err != nil {
+			return model.PaymentSummary{}, err
+		}
+		payment.PaidAt = payment.PaidAt.In(loc)
+		summary.Payments = append(summary.Payments, payment)
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,290 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	model "frappuccino/models"
+
+	"github.com/lib/pq"
+)
+
+type PromotionRepository interface {
+	Add(promotion model.Promotion) (int, error)
+	GetAll() ([]model.Promotion, error)
+	GetByID(id int) (model.Promotion, error)
+	Update(id int, promotion model.Promotion) error
+	Delete(id int) error
+}
+
+type Promotion struct {
+	db *sql.DB
+}
+
+func NewPromotionRepo(db *sql.DB) *Promotion {
+	return &Promotion{db: db}
+}
+
+var (
+	ErrPromotionNotFound = errors.New("promotion_not_found")
+	ErrInvalidPromoCode  = errors.New("invalid_promo_code")
+)
+
+// promotionColumns selects a promotion. Only promotions with a usage limit keep
+// count in times_used, so the uses are counted from order_discounts.
+const promotionColumns = `
+	promotion_id, name, COALESCE(code, ''), discount_type,
+	CASE WHEN discount_type = 'percentage' THEN value ELSE 0 END,
+	CASE WHEN discount_type = 'fixed' THEN value ELSE 0 END,
+	scope, COALESCE(tag, ''),
+	COALESCE(menu_item_id, 0), COALESCE(usage_limit, 0),
+	(SELECT COUNT(*) FROM order_discounts od WHERE od.promotion_id = promotions.promotion_id),
+	valid_from, valid_until, active`
+
+func (p *Promotion) Add(promotion model.Promotion) (int, error) {
+	var id int
+	err := p.db.QueryRow(`
+		INSERT INTO promotions (name, code, discount_type, value, scope, tag, menu_item_id, usage_limit, valid_from, valid_until, active)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, 0), $9, $10, $11)
+		RETURNING promotion_id
+	`, promotion.Name, promotion.Code, promotion.DiscountType, promotionValue(promotion), promotion.Scope, promotion.Tag,
+		promotion.MenuItemID, promotion.UsageLimit, promotion.ValidFrom, promotion.ValidUntil, promotion.Active).Scan(&id)
+	return id, err
+}
+
+func (p *Promotion) GetAll() ([]model.Promotion, error) {
+	rows, err := p.db.Query(`SELECT ` + promotionColumns + ` FROM promotions ORDER BY promotion_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []model.Promotion{}
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, promotion)
+	}
+	return promotions, rows.Err()
+}
+
+func (p *Promotion) GetByID(id int) (model.Promotion, error) {
+	promotion, err := scanPromotion(p.db.QueryRow(`SELECT `+promotionColumns+` FROM promotions WHERE promotion_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Promotion{}, fmt.Errorf("%w: promotion %d", ErrPromotionNotFound, id)
+	}
+	return promotion, err
+}
+
+// Update changes the rule of a promotion. The usage counter is recounted so
+// that a usage limit added later still counts the orders placed before.
+func (p *Promotion) Update(id int, promotion model.Promotion) error {
+	result, err := p.db.Exec(`
+		UPDATE promotions
+		SET name = $1, code = NULLIF($2, ''), discount_type = $3, value = $4, scope = $5, tag = NULLIF($6, ''),
+			menu_item_id = NULLIF($7, 0), usage_limit = NULLIF($8, 0), valid_from = $9, valid_until = $10, active = $11,
+			times_used = (SELECT COUNT(*) FROM order_discounts WHERE promotion_id = $12)
+		WHERE promotion_id = $12
+	`, promotion.Name, promotion.Code, promotion.DiscountType, promotionValue(promotion), promotion.Scope, promotion.Tag,
+		promotion.MenuItemID, promotion.UsageLimit, promotion.ValidFrom, promotion.ValidUntil, promotion.Active, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: promotion %d", ErrPromotionNotFound, id)
+	}
+	return nil
+}
+
+func (p *Promotion) Delete(id int) error {
+	result, err := p.db.Exec(`DELETE FROM promotions WHERE promotion_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: promotion %d", ErrPromotionNotFound, id)
+	}
+	return nil
+}
+
+// promotionValue is what goes into the value column: the percentage or, for a
+// fixed discount, the amount as an exact decimal.
+func promotionValue(promotion model.Promotion) interface{} {
+	if promotion.DiscountType == model.DiscountFixed {
+		return promotion.Amount
+	}
+	return promotion.Percent
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPromotion(row rowScanner) (model.Promotion, error) {
+	var promotion model.Promotion
+	var validFrom, validUntil sql.NullTime
+	err := row.Scan(&promotion.ID, &promotion.Name, &promotion.Code, &promotion.DiscountType, &promotion.Percent,
+		&promotion.Amount, &promotion.Scope, &promotion.Tag, &promotion.MenuItemID, &promotion.UsageLimit, &promotion.TimesUsed,
+		&validFrom, &validUntil, &promotion.Active)
+	if err != nil {
+		return model.Promotion{}, err
+	}
+	if validFrom.Valid {
+		promotion.ValidFrom = &validFrom.Time
+	}
+	if validUntil.Valid {
+		promotion.ValidUntil = &validUntil.Time
+	}
+	return promotion, nil
+}
+
+// applyPromotions works out the discounts for order lines: every automatic
+// promotion that is currently valid plus the one matching code. Nothing is
+// locked here; saveOrderDiscounts enforces usage limits when it counts the
+// uses. Discounts never take the order below zero.
+func applyPromotions(tx *sql.Tx, lines []orderLine, subtotal model.Money, code string) ([]model.OrderDiscount, model.Money, error) {
+	rows, err := tx.Query(`
+		SELECT `+promotionColumns+`
+		FROM promotions
+		WHERE active
+			AND (code IS NULL OR code = $1)
+			AND (valid_from IS NULL OR valid_from <= NOW())
+			AND (valid_until IS NULL OR valid_until > NOW())
+			AND (usage_limit IS NULL OR times_used < usage_limit)
+		ORDER BY promotion_id
+	`, code)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var promotions []model.Promotion
+	codeFound := code == ""
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		if promotion.Code != "" {
+			codeFound = true
+		}
+		promotions = append(promotions, promotion)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if !codeFound {
+		return nil, 0, fmt.Errorf("%w: '%s' is unknown, expired or used up", ErrInvalidPromoCode, code)
+	}
+
+	var discounts []model.OrderDiscount
+	var discountTotal model.Money
+	for _, promotion := range promotions {
+		var base model.Money
+		for _, line := range lines {
+			switch {
+			case promotion.Scope == model.ScopeOrder,
+				promotion.Scope == model.ScopeTag && slices.Contains(line.Tags, promotion.Tag),
+				promotion.Scope == model.ScopeItem && line.MenuItemID == promotion.MenuItemID:
+				base += line.UnitPrice.Mul(line.Quantity)
+			}
+		}
+
+		amount := min(promotion.Amount, base)
+		if promotion.DiscountType == model.DiscountPercentage {
+			amount = base.Percent(promotion.Percent)
+		}
+		amount = min(amount, subtotal-discountTotal)
+		if amount <= 0 {
+			continue
+		}
+
+		discounts = append(discounts, model.OrderDiscount{
+			PromotionID: promotion.ID,
+			Name:        promotion.Name,
+			Code:        promotion.Code,
+			Amount:      amount,
+		})
+		discountTotal += amount
+	}
+	return discounts, discountTotal, nil
+}
+
+// saveOrderDiscounts stores the discounts of an order and counts one use of
+// every promotion behind them that has a usage limit. The count is a single
+// UPDATE, so concurrent orders can not go over the limit, and only limited
+// promotions are written, so the others do not serialize orders. Discounts
+// without a promotion, such as redeemed loyalty points, are stored without one.
+func saveOrderDiscounts(tx *sql.Tx, orderID int, discounts []model.OrderDiscount) error {
+	for _, discount := range discounts {
+		_, err := tx.Exec(`
+			INSERT INTO order_discounts (order_id, promotion_id, name, code, amount)
+			VALUES ($1, NULLIF($2, 0), $3, NULLIF($4, ''), $5)
+		`, orderID, discount.PromotionID, discount.Name, discount.Code, discount.Amount)
+		if err != nil {
+			return err
+		}
+		if discount.PromotionID == 0 {
+			continue
+		}
+
+		var withinLimit bool
+		err = tx.QueryRow(`
+			UPDATE promotions
+			SET times_used = times_used + 1
+			WHERE promotion_id = $1 AND usage_limit IS NOT NULL
+			RETURNING times_used <= usage_limit
+		`, discount.PromotionID).Scan(&withinLimit)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue // no usage limit to count against
+		}
+		if err != nil {
+			return err
+		}
+		if !withinLimit {
+			return fmt.Errorf("%w: promotion '%s' is used up", ErrInvalidPromoCode, discount.Name)
+		}
+	}
+	return nil
+}
+
+// releaseOrderDiscounts removes the discounts of an order and gives the uses
+// back to their promotions.
+func releaseOrderDiscounts(tx *sql.Tx, orderID int) error {
+	rows, err := tx.Query(`
+		DELETE FROM order_discounts
+		WHERE order_id = $1
+		RETURNING promotion_id
+	`, orderID)
+	if err != nil {
+		return err
+	}
+
+	var promotionIDs []int64
+	for rows.Next() {
+		var promotionID sql.NullInt64
+		if err := rows.Scan(&promotionID); err != nil {
+			rows.Close()
+			return err
+		}
+		if promotionID.Valid {
+			promotionIDs = append(promotionIDs, promotionID.Int64)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE promotions
+		SET times_used = GREATEST(times_used - 1, 0)
+		WHERE promotion_id = ANY($1) AND usage_limit IS NOT NULL
+	`, pq.Array(promotionIDs))
+	return err
+}
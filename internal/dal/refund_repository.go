@@ -0,0 +1,260 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	model "frappuccino/models"
+)
+
+type RefundRepository interface {
+	Add(orderID int, request model.RefundRequest, loc *time.Location) (model.Refund, error)
+	GetByOrder(orderID int, loc *time.Location) ([]model.Refund, error)
+}
+
+type Refund struct {
+	db *sql.DB
+}
+
+func NewRefundRepo(db *sql.DB) *Refund {
+	return &Refund{db: db}
+}
+
+var (
+	ErrOrderNotRefundable = errors.New("order_not_refundable")
+	ErrRefundExceedsOrder = errors.New("refund_exceeds_order")
+	ErrOrderItemNotFound  = errors.New("order_item_not_found")
+)
+
+// refundableItem is an order item together with what has already been
+// refunded of it.
+type refundableItem struct {
+	UnitPrice model.Money
+	Quantity  int
+	Refunded  int
+}
+
+// Add refunds a closed order, either line by line or, with no items given,
+// everything that has not been refunded yet. Line amounts are scaled by the
+// ratio of the order total without the tip to its items so that discounts and
+// taxes are refunded proportionally; the tip is only returned by a full refund.
+// Loyalty points the order earned are taken back in the same proportion.
+func (r *Refund) Add(orderID int, request model.RefundRequest, loc *time.Location) (model.Refund, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.Refund{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var status string
+	if status, err = lockOrder(tx, orderID); err != nil {
+		return model.Refund{}, err
+	}
+	if status != model.StatusClosed {
+		err = fmt.Errorf("%w: order %d is %s", ErrOrderNotRefundable, orderID, status)
+		return model.Refund{}, err
+	}
+
+	var total, tip, refunded model.Money
+	err = tx.QueryRow(`
+		SELECT o.total_amount, o.tip_amount, COALESCE((SELECT SUM(amount) FROM refunds WHERE order_id = o.order_id), 0)
+		FROM orders o
+		WHERE o.order_id = $1
+	`, orderID).Scan(&total, &tip, &refunded)
+	if err != nil {
+		return model.Refund{}, err
+	}
+	remaining := total - refunded
+
+	var items map[int]*refundableItem
+	var subtotal model.Money
+	if items, subtotal, err = refundableItems(tx, orderID); err != nil {
+		return model.Refund{}, err
+	}
+
+	refund := model.Refund{
+		OrderID: orderID,
+		Reason:  request.Reason,
+		Note:    request.Note,
+		Items:   []model.RefundItem{},
+	}
+
+	if len(request.Items) == 0 {
+		orderItemIDs := make([]int, 0, len(items))
+		for orderItemID := range items {
+			orderItemIDs = append(orderItemIDs, orderItemID)
+		}
+		sort.Ints(orderItemIDs)
+
+		for _, orderItemID := range orderItemIDs {
+			if left := items[orderItemID].Quantity - items[orderItemID].Refunded; left > 0 {
+				refund.Items = append(refund.Items, model.RefundItem{OrderItemID: orderItemID, Quantity: left})
+			}
+		}
+		refund.Amount = remaining
+	} else {
+		for _, line := range request.Items {
+			item, ok := items[line.OrderItemID]
+			if !ok {
+				err = fmt.Errorf("%w: item %d is not part of order %d", ErrOrderItemNotFound, line.OrderItemID, orderID)
+				return model.Refund{}, err
+			}
+			if item.Refunded+line.Quantity > item.Quantity {
+				err = fmt.Errorf("%w: only %d of item %d left to refund", ErrRefundExceedsOrder, item.Quantity-item.Refunded, line.OrderItemID)
+				return model.Refund{}, err
+			}
+			item.Refunded += line.Quantity
+			refund.Items = append(refund.Items, model.RefundItem{OrderItemID: line.OrderItemID, Quantity: line.Quantity})
+		}
+	}
+
+	for i := range refund.Items {
+		amount := items[refund.Items[i].OrderItemID].UnitPrice.Mul(refund.Items[i].Quantity)
+		refund.Items[i].Amount = amount.Share(total-tip, subtotal)
+		if len(request.Items) != 0 {
+			refund.Amount += refund.Items[i].Amount
+		}
+	}
+	if refund.Amount > remaining {
+		refund.Amount = remaining
+	}
+	if refund.Amount <= 0 {
+		err = fmt.Errorf("%w: order %d has nothing left to refund", ErrRefundExceedsOrder, orderID)
+		return model.Refund{}, err
+	}
+
+	if request.Restock {
+		if err = restockRefundItems(tx, orderID, refund.Items); err != nil {
+			return model.Refund{}, err
+		}
+		refund.Restocked = true
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO refunds (order_id, reason, amount, restocked, note)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING refund_id, created_at
+	`, orderID, refund.Reason, refund.Amount, refund.Restocked, refund.Note).Scan(&refund.RefundID, &refund.CreatedAt)
+	if err != nil {
+		return model.Refund{}, err
+	}
+
+	for _, item := range refund.Items {
+		_, err = tx.Exec(`
+			INSERT INTO refund_items (refund_id, order_item_id, quantity, amount)
+			VALUES ($1, $2, $3, $4)
+		`, refund.RefundID, item.OrderItemID, item.Quantity, item.Amount)
+		if err != nil {
+			return model.Refund{}, err
+		}
+	}
+
+	if err = reverseEarnedPoints(tx, orderID); err != nil {
+		return model.Refund{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return model.Refund{}, err
+	}
+	refund.CreatedAt = refund.CreatedAt.In(loc)
+	return refund, nil
+}
+
+func (r *Refund) GetByOrder(orderID int, loc *time.Location) ([]model.Refund, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM orders WHERE order_id = $1)`, orderID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: order %d", ErrOrderNotFound, orderID)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT r.refund_id, r.order_id, r.reason, r.amount, r.restocked, COALESCE(r.note, ''), r.created_at,
+			ri.order_item_id, ri.quantity, ri.amount
+		FROM refunds r
+		JOIN refund_items ri ON ri.refund_id = r.refund_id
+		WHERE r.order_id = $1
+		ORDER BY r.created_at, r.refund_id, ri.id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []model.Refund{}
+	for rows.Next() {
+		var refund model.Refund
+		var item model.RefundItem
+		if err := rows.Scan(&refund.RefundID, &refund.OrderID, &refund.Reason, &refund.Amount, &refund.Restocked,
+			&refund.Note, &refund.CreatedAt, &item.OrderItemID, &item.Quantity, &item.Amount); err != nil {
+			return nil, err
+		}
+		refund.CreatedAt = refund.CreatedAt.In(loc)
+		if n := len(refunds); n > 0 && refunds[n-1].RefundID == refund.RefundID {
+			refunds[n-1].Items = append(refunds[n-1].Items, item)
+			continue
+		}
+		refund.Items = []model.RefundItem{item}
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+// refundableItems loads the items of an order with the quantities already
+// refunded, and the sum of the items at the prices they were sold for.
+func refundableItems(tx *sql.Tx, orderID int) (map[int]*refundableItem, model.Money, error) {
+	rows, err := tx.Query(`
+		SELECT oi.order_item_id, oi.price_at_order_time, oi.quantity,
+			COALESCE((SELECT SUM(ri.quantity) FROM refund_items ri WHERE ri.order_item_id = oi.order_item_id), 0)
+		FROM order_items oi
+		WHERE oi.order_id = $1
+	`, orderID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := make(map[int]*refundableItem)
+	var subtotal model.Money
+	for rows.Next() {
+		var orderItemID int
+		var item refundableItem
+		if err := rows.Scan(&orderItemID, &item.UnitPrice, &item.Quantity, &item.Refunded); err != nil {
+			return nil, 0, err
+		}
+		items[orderItemID] = &item
+		subtotal += item.UnitPrice.Mul(item.Quantity)
+	}
+	return items, subtotal, rows.Err()
+}
+
+// restockRefundItems returns the ingredients of the refunded quantities to
+// inventory.
+func restockRefundItems(tx *sql.Tx, orderID int, refunded []model.RefundItem) error {
+	stored, err := storedOrderItems(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	portions := make(map[int]map[int]float64, len(stored))
+	for _, item := range stored {
+		portions[item.OrderItemID] = item.Portion
+	}
+
+	delta := make(map[int]float64)
+	for _, item := range refunded {
+		for inventoryID, qty := range portions[item.OrderItemID] {
+			delta[inventoryID] -= qty * float64(item.Quantity)
+		}
+	}
+	return applyStockDelta(tx, orderID, delta)
+}
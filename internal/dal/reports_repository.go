@@ -3,6 +3,7 @@ package dal
 import (
 	"database/sql"
 	"strconv"
+	"time"
 
 	model "frappuccino/models"
 
@@ -10,12 +11,14 @@ import (
 )
 
 type ReportsDalInterface interface {
-	TotalPrice() (model.TotalSalesStruct, error)
-	PopularItems(limit string) ([]model.PopularItem, error)
+	TotalPrice(byPaymentMethod bool) (model.TotalSalesStruct, error)
+	PopularItems(limit string, bySize bool) ([]model.PopularItem, error)
 	FullTextSearchMenu(q, minPrice, maxPrice string) (int, []model.MenuItemResult, error)
 	FullTextSearchOrder(q, minPrice, maxPrice string) (int, []model.OrderResult, error)
-	OrderedItemsByPeriodDay(month int) (model.ItemByPeriodMonth, error)
-	OrderedItemsByPeriodMonth(year int) (model.ItemByPeriodYear, error)
+	OrderedItemsByPeriodDay(month int, loc *time.Location) (model.ItemByPeriodMonth, error)
+	OrderedItemsByPeriodMonth(year int, loc *time.Location) (model.ItemByPeriodYear, error)
+	StatusDurations(startDate, endDate interface{}, loc *time.Location) ([]model.StatusDuration, error)
+	GiftCardLiability() (model.GiftCardLiability, error)
 }
 
 type ReportsData struct {
@@ -26,40 +29,122 @@ func NewReportsRepo(db *sql.DB) *ReportsData {
 	return &ReportsData{db: db}
 }
 
-func (f *ReportsData) TotalPrice() (model.TotalSalesStruct, error) {
-	query := `SELECT COALESCE(SUM(total_amount), 0) FROM orders
+// TotalPrice sums the revenue of closed orders and its components: gross
+// sales before discounts, the discounts given, the tax collected inside and on
+// top of the prices, the tips, the total charged and what was refunded from
+// it, and the total less the refunds. With byPaymentMethod the revenue is also
+// split by the tenders the orders were paid with.
+func (f *ReportsData) TotalPrice(byPaymentMethod bool) (model.TotalSalesStruct, error) {
+	query := `SELECT
+				COALESCE(SUM(subtotal), 0),
+				COALESCE(SUM(discount_amount), 0),
+				COALESCE(SUM(tip_amount), 0),
+				COALESCE(SUM(total_amount), 0),
+				COALESCE((SELECT SUM(r.amount) FROM refunds r
+						  JOIN orders o ON o.order_id = r.order_id
+						  WHERE o.status = 'closed'), 0)
+			  FROM orders
 			  WHERE status = 'closed'`
 
 	var totalPrice model.TotalSalesStruct
-	if err := f.db.QueryRow(query).Scan(&totalPrice.TotalSales); err != nil {
+	if err := f.db.QueryRow(query).Scan(&totalPrice.GrossSales, &totalPrice.Discounts, &totalPrice.Tips,
+		&totalPrice.TotalSales, &totalPrice.Refunds); err != nil {
+		return model.TotalSalesStruct{}, err
+	}
+	totalPrice.NetSales = totalPrice.GrossSales - totalPrice.Discounts
+	totalPrice.NetOfRefunds = totalPrice.TotalSales - totalPrice.Refunds
+
+	taxRows, err := f.db.Query(`
+		SELECT ot.name, ot.inclusive, SUM(ot.amount)
+		FROM order_taxes ot
+		JOIN orders o ON o.order_id = ot.order_id
+		WHERE o.status = 'closed'
+		GROUP BY ot.name, ot.inclusive
+		ORDER BY ot.name`)
+	if err != nil {
+		return model.TotalSalesStruct{}, err
+	}
+	defer taxRows.Close()
+
+	totalPrice.ByTaxRate = make(map[string]model.Money)
+	for taxRows.Next() {
+		var name string
+		var inclusive bool
+		var amount model.Money
+		if err := taxRows.Scan(&name, &inclusive, &amount); err != nil {
+			return model.TotalSalesStruct{}, err
+		}
+		totalPrice.ByTaxRate[name] += amount
+		if inclusive {
+			totalPrice.InclusiveTax += amount
+		} else {
+			totalPrice.ExclusiveTax += amount
+		}
+	}
+	if err := taxRows.Err(); err != nil {
 		return model.TotalSalesStruct{}, err
 	}
 
-	return totalPrice, nil
+	if !byPaymentMethod {
+		return totalPrice, nil
+	}
+
+	rows, err := f.db.Query(`
+		SELECT p.method, SUM(p.amount)
+		FROM payments p
+		JOIN orders o ON o.order_id = p.order_id
+		WHERE o.status = 'closed'
+		GROUP BY p.method
+		ORDER BY p.method`)
+	if err != nil {
+		return model.TotalSalesStruct{}, err
+	}
+	defer rows.Close()
+
+	totalPrice.ByPaymentMethod = make(map[string]model.Money)
+	for rows.Next() {
+		var method string
+		var amount model.Money
+		if err := rows.Scan(&method, &amount); err != nil {
+			return model.TotalSalesStruct{}, err
+		}
+		totalPrice.ByPaymentMethod[method] = amount
+	}
+	return totalPrice, rows.Err()
 }
 
-func (f *ReportsData) PopularItems(limit string) ([]model.PopularItem, error) {
-	query := `SELECT mi.name, SUM(oi.quantity) AS totalQuant
+// PopularItems ranks menu items by the quantity sold in closed orders, net of
+// refunded quantities. With bySize every size of an item is ranked separately.
+func (f *ReportsData) PopularItems(limit string, bySize bool) ([]model.PopularItem, error) {
+	query := `SELECT mi.name, CASE WHEN $2 THEN oi.size::TEXT ELSE '' END AS size,
+				SUM(oi.quantity - COALESCE(ri.quantity, 0)) AS totalQuant
 			  FROM menu_items mi
 			  JOIN order_items oi ON mi.menu_item_id = oi.menu_item_id
 			  JOIN orders o ON oi.order_id = o.order_id
+			  LEFT JOIN (
+				SELECT order_item_id, SUM(quantity) AS quantity
+				FROM refund_items
+				GROUP BY order_item_id
+			  ) ri ON ri.order_item_id = oi.order_item_id
 			  WHERE o.status = 'closed'
-			  GROUP BY mi.name
+			  GROUP BY mi.name, 2
+			  HAVING SUM(oi.quantity - COALESCE(ri.quantity, 0)) > 0
 			  ORDER BY totalQuant DESC
 			  LIMIT $1
 		`
 
-	rows, err := f.db.Query(query, limit)
+	rows, err := f.db.Query(query, limit, bySize)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	var items []model.PopularItem
 
 	for rows.Next() {
 		var item model.PopularItem
 
-		if err := rows.Scan(&item.Name, &item.Quantity); err != nil {
+		if err := rows.Scan(&item.Name, &item.Size, &item.Quantity); err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -113,19 +198,18 @@ func (f *ReportsData) FullTextSearchOrder(q, minPrice, maxPrice string) (int, []
 		SELECT 
 			o.order_id AS id,
 			o.customer_name,
-			array_agg(mi.name) AS items,
+			array_agg(oi.item_name) AS items,
 			o.total_amount AS total,
 			ts_rank(
 				setweight(to_tsvector('english', o.customer_name), 'A') || 
-				setweight(to_tsvector('english', string_agg(mi.name, ' ')), 'B'),
+				setweight(to_tsvector('english', string_agg(oi.item_name, ' ')), 'B'),
 				plainto_tsquery('english', $1)
 			) AS relevance
 		FROM orders o
 		JOIN order_items oi ON o.order_id = oi.order_id
-		JOIN menu_items mi ON oi.menu_item_id = mi.menu_item_id
 		WHERE 
 			(to_tsvector('english', o.customer_name) @@ plainto_tsquery('english', $1)
-			 OR to_tsvector('english', mi.name) @@ plainto_tsquery('english', $1))
+			 OR to_tsvector('english', oi.item_name) @@ plainto_tsquery('english', $1))
 			AND ($2::NUMERIC IS NULL OR o.total_amount >= $2::NUMERIC)
 			AND ($3::NUMERIC IS NULL OR o.total_amount <= $3::NUMERIC)
 		GROUP BY o.order_id, o.customer_name, o.total_amount
@@ -150,14 +234,16 @@ func (f *ReportsData) FullTextSearchOrder(q, minPrice, maxPrice string) (int, []
 	return totalMatches, orderResults, nil
 }
 
-func (f *ReportsData) OrderedItemsByPeriodDay(month int) (model.ItemByPeriodMonth, error) {
-	query := `SELECT EXTRACT(DAY FROM order_date) AS day, COUNT(*) AS orders
+// OrderedItemsByPeriodDay counts the orders placed on each day of a month, the
+// days being those of loc.
+func (f *ReportsData) OrderedItemsByPeriodDay(month int, loc *time.Location) (model.ItemByPeriodMonth, error) {
+	query := `SELECT EXTRACT(DAY FROM order_date AT TIME ZONE $2::TEXT) AS day, COUNT(*) AS orders
 			  FROM orders
-			  WHERE EXTRACT(MONTH FROM order_date) = $1
+			  WHERE EXTRACT(MONTH FROM order_date AT TIME ZONE $2::TEXT) = $1
 			  GROUP BY day
 			  ORDER BY day`
 
-	rows, err := f.db.Query(query, month)
+	rows, err := f.db.Query(query, month, loc.String())
 	if err != nil {
 		return model.ItemByPeriodMonth{}, err
 	}
@@ -181,14 +267,16 @@ func (f *ReportsData) OrderedItemsByPeriodDay(month int) (model.ItemByPeriodMont
 	return itemPeriodMonth, nil
 }
 
-func (f *ReportsData) OrderedItemsByPeriodMonth(year int) (model.ItemByPeriodYear, error) {
-	query := `SELECT EXTRACT(MONTH FROM order_date) AS month, COUNT(*) AS orders
+// OrderedItemsByPeriodMonth counts the orders placed in each month of a year,
+// the months being those of loc.
+func (f *ReportsData) OrderedItemsByPeriodMonth(year int, loc *time.Location) (model.ItemByPeriodYear, error) {
+	query := `SELECT EXTRACT(MONTH FROM order_date AT TIME ZONE $2::TEXT) AS month, COUNT(*) AS orders
 			  FROM orders
-			  WHERE EXTRACT(YEAR FROM order_date) = $1
+			  WHERE EXTRACT(YEAR FROM order_date AT TIME ZONE $2::TEXT) = $1
 			  GROUP BY month
 			  ORDER BY month`
 
-	rows, err := f.db.Query(query, year)
+	rows, err := f.db.Query(query, year, loc.String())
 	if err != nil {
 		return model.ItemByPeriodYear{}, err
 	}
@@ -215,6 +303,62 @@ func (f *ReportsData) OrderedItemsByPeriodMonth(year int) (model.ItemByPeriodYea
 	return itemByPeriodYear, nil
 }
 
+// StatusDurations averages how long orders placed in the given date range,
+// days in loc, stayed in each status. Only finished stays, i.e. ones followed by another
+// status change, are counted.
+func (f *ReportsData) StatusDurations(startDate, endDate interface{}, loc *time.Location) ([]model.StatusDuration, error) {
+	query := `
+		WITH stays AS (
+			SELECT
+				h.order_id,
+				h.status,
+				h.changed_at,
+				LEAD(h.changed_at) OVER (PARTITION BY h.order_id ORDER BY h.changed_at, h.id) AS left_at
+			FROM order_status_history h
+			JOIN orders o ON o.order_id = h.order_id
+			WHERE
+				($1::DATE IS NULL OR o.order_date >= $1::DATE::TIMESTAMP AT TIME ZONE $3::TEXT)
+				AND ($2::DATE IS NULL OR o.order_date < ($2::DATE + 1)::TIMESTAMP AT TIME ZONE $3::TEXT)
+		)
+		SELECT status, COUNT(DISTINCT order_id), AVG(EXTRACT(EPOCH FROM left_at - changed_at))
+		FROM stays
+		WHERE left_at IS NOT NULL
+		GROUP BY status
+		ORDER BY status`
+
+	rows, err := f.db.Query(query, startDate, endDate, loc.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	durations := []model.StatusDuration{}
+	for rows.Next() {
+		var duration model.StatusDuration
+		if err := rows.Scan(&duration.Status, &duration.Orders, &duration.AverageSeconds); err != nil {
+			return nil, err
+		}
+		durations = append(durations, duration)
+	}
+	return durations, rows.Err()
+}
+
+// GiftCardLiability sums what was loaded onto gift cards, what was spent from
+// them and the balance still owed to card holders. Card sales are not revenue
+// until redeemed, so none of this is part of TotalPrice.
+func (f *ReportsData) GiftCardLiability() (model.GiftCardLiability, error) {
+	var liability model.GiftCardLiability
+	err := f.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM gift_cards),
+			COALESCE(SUM(amount) FILTER (WHERE transaction_type IN ('issue', 'top_up')), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE transaction_type = 'redeem'), 0),
+			(SELECT COALESCE(SUM(balance), 0) FROM gift_cards)
+		FROM gift_card_transactions
+	`).Scan(&liability.Cards, &liability.Issued, &liability.Redeemed, &liability.Outstanding)
+	return liability, err
+}
+
 func monthToString(m int) string {
 	months := map[int]string{
 		1:  "january",
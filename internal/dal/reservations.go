@@ -0,0 +1,107 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// reserveStock sets ingredients aside for an order without taking them out of
+// inventory. Only stock that is not already reserved for other orders can be
+// reserved, so open orders never promise the same ingredients twice. The check
+// and the reservation are one conditional UPDATE, and ingredients are handled
+// in id order so concurrent orders lock rows in the same order.
+func reserveStock(tx *sql.Tx, orderID int, needs map[int]float64) error {
+	for _, inventoryID := range slices.Sorted(maps.Keys(needs)) {
+		qty := math.Round(needs[inventoryID]*100) / 100
+		if qty <= 0 {
+			continue
+		}
+
+		result, err := tx.Exec(`
+			UPDATE inventory
+			SET reserved_level = reserved_level + $1
+			WHERE inventory_id = $2 AND stock_level - reserved_level >= $1
+		`, qty, inventoryID)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return notEnoughStock(tx, inventoryID, qty, `stock_level - reserved_level`)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO order_reservations (order_id, inventory_id, quantity)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (order_id, inventory_id) DO UPDATE SET quantity = order_reservations.quantity + EXCLUDED.quantity
+		`, orderID, inventoryID, qty)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notEnoughStock builds the error for an ingredient that could not cover qty,
+// reporting the quantity given by the have expression.
+func notEnoughStock(tx *sql.Tx, inventoryID int, qty float64, have string) error {
+	var available float64
+	if err := tx.QueryRow(`SELECT `+have+` FROM inventory WHERE inventory_id = $1`, inventoryID).Scan(&available); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: not enough stock for ingredient %d: need %.2f, have %.2f", ErrNotEnoughStock, inventoryID, qty, available)
+}
+
+// releaseReservations drops the reservations of an order and returns the
+// quantities that were reserved.
+func releaseReservations(tx *sql.Tx, orderID int) (map[int]float64, error) {
+	rows, err := tx.Query(`
+		DELETE FROM order_reservations
+		WHERE order_id = $1
+		RETURNING inventory_id, quantity
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[int]float64)
+	for rows.Next() {
+		var inventoryID int
+		var qty float64
+		if err := rows.Scan(&inventoryID, &qty); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reserved[inventoryID] = qty
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, inventoryID := range slices.Sorted(maps.Keys(reserved)) {
+		qty := reserved[inventoryID]
+		_, err := tx.Exec(`
+			UPDATE inventory
+			SET reserved_level = GREATEST(reserved_level - $1, 0)
+			WHERE inventory_id = $2
+		`, qty, inventoryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reserved, nil
+}
+
+// consumeReservations turns the reservations of an order into consumption
+// once it has been made. The ingredients are gone either way, so only the stock
+// on hand is checked, not what other orders have reserved.
+func consumeReservations(tx *sql.Tx, orderID int) error {
+	reserved, err := releaseReservations(tx, orderID)
+	if err != nil {
+		return err
+	}
+	return applyStockDelta(tx, orderID, reserved)
+}
@@ -0,0 +1,91 @@
+package dal
+
+import (
+	"time"
+
+	model "frappuccino/models"
+)
+
+// Reasons a scheduled order can not be fulfilled, stored in
+// orders.fulfillment_issue.
+const (
+	IssueMenuItemRemoved = "menu_item_removed"
+	IssueNotEnoughStock  = "insufficient_inventory"
+)
+
+// FlagUnfulfillable re-checks every scheduled order and records why it can no
+// longer be made: one of its items left the menu, or inventory was adjusted
+// below what is reserved for it. Flags that no longer apply are cleared. It
+// returns the orders whose flag changed, with an empty reason when cleared.
+func (o *Order) FlagUnfulfillable() (map[int]string, error) {
+	rows, err := o.db.Query(`
+		WITH issues AS (
+			SELECT
+				o.order_id,
+				CASE
+					WHEN EXISTS (
+						SELECT 1 FROM order_items oi
+						WHERE oi.order_id = o.order_id AND oi.menu_item_id IS NULL
+					) THEN $1
+					WHEN EXISTS (
+						SELECT 1 FROM order_reservations r
+						JOIN inventory i ON i.inventory_id = r.inventory_id
+						WHERE r.order_id = o.order_id AND i.stock_level < i.reserved_level
+					) THEN $2
+				END AS issue
+			FROM orders o
+			WHERE o.status = 'scheduled'
+		)
+		UPDATE orders o
+		SET fulfillment_issue = issues.issue
+		FROM issues
+		WHERE o.order_id = issues.order_id AND o.fulfillment_issue IS DISTINCT FROM issues.issue
+		RETURNING o.order_id, COALESCE(o.fulfillment_issue, '')
+	`, IssueMenuItemRemoved, IssueNotEnoughStock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changed := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var issue string
+		if err := rows.Scan(&id, &issue); err != nil {
+			return nil, err
+		}
+		changed[id] = issue
+	}
+	return changed, rows.Err()
+}
+
+// DueScheduled returns the scheduled orders without a fulfillment issue that
+// are to be picked up before the given time, earliest first.
+func (o *Order) DueScheduled(before time.Time) ([]int, error) {
+	rows, err := o.db.Query(`
+		SELECT order_id
+		FROM orders
+		WHERE status = 'scheduled' AND fulfillment_issue IS NULL AND pickup_at <= $1
+		ORDER BY pickup_at, order_id
+	`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ReleaseScheduled moves a scheduled order into the queue as pending. Its
+// ingredients stay reserved until the order is closed.
+func (o *Order) ReleaseScheduled(id int) error {
+	return o.UpdateStatus(id, []string{model.StatusScheduled}, model.StatusPending)
+}
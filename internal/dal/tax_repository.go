@@ -0,0 +1,171 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	model "frappuccino/models"
+)
+
+type TaxRepository interface {
+	Add(rate model.TaxRate) (int, error)
+	GetAll() ([]model.TaxRate, error)
+	GetByID(id int) (model.TaxRate, error)
+	Update(id int, rate model.TaxRate) error
+	Delete(id int) error
+}
+
+type Tax struct {
+	db *sql.DB
+}
+
+func NewTaxRepo(db *sql.DB) *Tax {
+	return &Tax{db: db}
+}
+
+var ErrTaxRateNotFound = errors.New("tax_rate_not_found")
+
+const taxRateColumns = `tax_rate_id, name, rate, COALESCE(tag, ''), inclusive, active`
+
+func (t *Tax) Add(rate model.TaxRate) (int, error) {
+	var id int
+	err := t.db.QueryRow(`
+		INSERT INTO tax_rates (name, rate, tag, inclusive, active)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING tax_rate_id
+	`, rate.Name, rate.Rate, rate.Tag, rate.Inclusive, rate.Active).Scan(&id)
+	return id, err
+}
+
+func (t *Tax) GetAll() ([]model.TaxRate, error) {
+	rows, err := t.db.Query(`SELECT ` + taxRateColumns + ` FROM tax_rates ORDER BY tax_rate_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []model.TaxRate{}
+	for rows.Next() {
+		var rate model.TaxRate
+		if err := rows.Scan(&rate.ID, &rate.Name, &rate.Rate, &rate.Tag, &rate.Inclusive, &rate.Active); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+func (t *Tax) GetByID(id int) (model.TaxRate, error) {
+	var rate model.TaxRate
+	err := t.db.QueryRow(`SELECT `+taxRateColumns+` FROM tax_rates WHERE tax_rate_id = $1`, id).
+		Scan(&rate.ID, &rate.Name, &rate.Rate, &rate.Tag, &rate.Inclusive, &rate.Active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TaxRate{}, fmt.Errorf("%w: tax rate %d", ErrTaxRateNotFound, id)
+	}
+	return rate, err
+}
+
+// Update changes a tax rate for orders placed from now on; orders already
+// placed keep the taxes stored with them.
+func (t *Tax) Update(id int, rate model.TaxRate) error {
+	result, err := t.db.Exec(`
+		UPDATE tax_rates
+		SET name = $1, rate = $2, tag = NULLIF($3, ''), inclusive = $4, active = $5
+		WHERE tax_rate_id = $6
+	`, rate.Name, rate.Rate, rate.Tag, rate.Inclusive, rate.Active, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: tax rate %d", ErrTaxRateNotFound, id)
+	}
+	return nil
+}
+
+func (t *Tax) Delete(id int) error {
+	result, err := t.db.Exec(`DELETE FROM tax_rates WHERE tax_rate_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: tax rate %d", ErrTaxRateNotFound, id)
+	}
+	return nil
+}
+
+// applyTaxes works out the taxes of order lines under the active tax rates.
+// Order discounts are spread over the lines in proportion to their amounts
+// before tax is charged. It returns the taxes and the part of them that is
+// added on top of the prices.
+func applyTaxes(tx *sql.Tx, lines []orderLine, subtotal, discount model.Money) ([]model.OrderTax, model.Money, error) {
+	rows, err := tx.Query(`SELECT ` + taxRateColumns + ` FROM tax_rates WHERE active ORDER BY tax_rate_id`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var rates []model.TaxRate
+	for rows.Next() {
+		var rate model.TaxRate
+		if err := rows.Scan(&rate.ID, &rate.Name, &rate.Rate, &rate.Tag, &rate.Inclusive, &rate.Active); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		rates = append(rates, rate)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var taxes []model.OrderTax
+	var exclusive model.Money
+	for _, rate := range rates {
+		var base model.Money
+		for _, line := range lines {
+			if rate.Tag == "" || slices.Contains(line.Tags, rate.Tag) {
+				base += line.UnitPrice.Mul(line.Quantity)
+			}
+		}
+		base = base.Share(subtotal-discount, subtotal)
+
+		amount := base.Percent(rate.Rate)
+		if rate.Inclusive {
+			amount = base.IncludedPercent(rate.Rate)
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		taxes = append(taxes, model.OrderTax{
+			TaxRateID: rate.ID,
+			Name:      rate.Name,
+			Rate:      rate.Rate,
+			Inclusive: rate.Inclusive,
+			Amount:    amount,
+		})
+		if !rate.Inclusive {
+			exclusive += amount
+		}
+	}
+	return taxes, exclusive, nil
+}
+
+// saveOrderTaxes replaces the stored taxes of an order.
+func saveOrderTaxes(tx *sql.Tx, orderID int, taxes []model.OrderTax) error {
+	if _, err := tx.Exec(`DELETE FROM order_taxes WHERE order_id = $1`, orderID); err != nil {
+		return err
+	}
+
+	for _, tax := range taxes {
+		_, err := tx.Exec(`
+			INSERT INTO order_taxes (order_id, tax_rate_id, name, rate, inclusive, amount)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, orderID, tax.TaxRateID, tax.Name, tax.Rate, tax.Inclusive, tax.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+)
+
+type CustomerHandler struct {
+	service service.CustomerService
+}
+
+func NewCustomerHandler(service service.CustomerService) *CustomerHandler {
+	return &CustomerHandler{service: service}
+}
+
+func (c *CustomerHandler) Add(w http.ResponseWriter, r *http.Request) {
+	var customer models.Customer
+	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+		SendResponse("Invalid request payload", err, http.StatusBadRequest, w)
+		return
+	}
+
+	id, err := c.service.Add(customer)
+	if err != nil {
+		SendResponse("Failed to add customer", err, customerErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Customer added successfully",
+		"customer_id": id,
+	})
+}
+
+func (c *CustomerHandler) Get(w http.ResponseWriter, r *http.Request) {
+	customers, err := c.service.GetAll()
+	if err != nil {
+		SendResponse("Failed to load customers", err, http.StatusInternalServerError, w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(customers); err != nil {
+		return
+	}
+}
+
+func (c *CustomerHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Error convert string to int", err, http.StatusNotFound, w)
+		return
+	}
+	customer, err := c.service.GetByID(id)
+	if err != nil {
+		SendResponse("Customer not found", err, customerErrorStatus(err), w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(customer); err != nil {
+		return
+	}
+}
+
+func (c *CustomerHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+
+	var customer models.Customer
+	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+		SendResponse("Invalid request payload", err, http.StatusBadRequest, w)
+		return
+	}
+
+	if err := c.service.Update(id, customer); err != nil {
+		SendResponse("Failed to update customer", err, customerErrorStatus(err), w)
+		return
+	}
+	SendResponse("Customer updated successfully", nil, http.StatusOK, w)
+}
+
+func (c *CustomerHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+	if err := c.service.Delete(id); err != nil {
+		SendResponse("Failed to delete customer", err, customerErrorStatus(err), w)
+		return
+	}
+	SendResponse("Customer deleted successfully", nil, http.StatusNoContent, w)
+}
+
+func (c *CustomerHandler) Orders(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Error convert string to int", err, http.StatusNotFound, w)
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+	orders, err := c.service.Orders(id, loc)
+	if err != nil {
+		SendResponse("Failed to load customer orders", err, customerErrorStatus(err), w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(orders); err != nil {
+		return
+	}
+}
+
+func customerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dal.ErrCustomerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dal.ErrCustomerExists):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidCustomer):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+)
+
+type GiftCardHandler struct {
+	service service.GiftCardService
+}
+
+func NewGiftCardHandler(service service.GiftCardService) *GiftCardHandler {
+	return &GiftCardHandler{service: service}
+}
+
+func (g *GiftCardHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+	var request models.GiftCardRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendResponse("Invalid request payload", err, http.StatusBadRequest, w)
+		return
+	}
+
+	card, err := g.service.Issue(request, loc)
+	if err != nil {
+		SendResponse("Failed to issue gift card", err, giftCardErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(card)
+}
+
+func (g *GiftCardHandler) GetByCode(w http.ResponseWriter, r *http.Request) {
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+	card, err := g.service.GetByCode(r.PathValue("code"), loc)
+	if err != nil {
+		SendResponse("Gift card not found", err, giftCardErrorStatus(err), w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(card); err != nil {
+		return
+	}
+}
+
+func (g *GiftCardHandler) TopUp(w http.ResponseWriter, r *http.Request) {
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+	var request models.GiftCardRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendResponse("Invalid request payload", err, http.StatusBadRequest, w)
+		return
+	}
+
+	card, err := g.service.TopUp(r.PathValue("code"), request, loc)
+	if err != nil {
+		SendResponse("Failed to top up gift card", err, giftCardErrorStatus(err), w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(card); err != nil {
+		return
+	}
+}
+
+func giftCardErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dal.ErrGiftCardNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dal.ErrGiftCardExists):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidGiftCard):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
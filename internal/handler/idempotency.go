@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"frappuccino/config"
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
+)
+
+// Idempotent makes a handler safe to retry. A request with an Idempotency-Key
+// header is handled once; repeating it with the same key replays the stored
+// response, and sending a different request with the key is rejected.
+// Responses with a 5xx status are not kept, nor is anything when the handler
+// panics, so such requests can be retried.
+func Idempotent(idempotency service.IdempotencyService, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			SendResponse("Failed to read request", err, http.StatusBadRequest, w)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		request := append([]byte(r.Method+" "+r.URL.RequestURI()+"\n"), body...)
+		stored, claimed, err := idempotency.Claim(key, request)
+		if err != nil {
+			SendResponse("Failed to check idempotency key", err, idempotencyErrorStatus(err), w)
+			return
+		}
+		if !claimed {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.StatusCode)
+			w.Write(stored.Body)
+			return
+		}
+
+		release := func() {
+			if err := idempotency.Release(key); err != nil {
+				config.Logger.Error("Failed to release idempotency key", "key", key, "error", err)
+			}
+		}
+		// a panicking handler must not leave the key claimed until it expires
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			release()
+			return
+		}
+		if err := idempotency.Complete(key, recorder.status, recorder.body.Bytes()); err != nil {
+			config.Logger.Error("Failed to store idempotent response", "key", key, "error", err)
+		}
+	}
+}
+
+// responseRecorder writes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func idempotencyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidIdempotencyKey):
+		return http.StatusBadRequest
+	case errors.Is(err, dal.ErrIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, dal.ErrIdempotencyKeyInProgress):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -36,7 +36,13 @@ func (h *Inventory) Add(w http.ResponseWriter, r *http.Request) {
 
 func (h *Inventory) Get(w http.ResponseWriter, r *http.Request) {
 	config.Logger.Info("Incoming Request Received", "Action", "Get")
-	items, err := h.inventoryService.Get()
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	items, err := h.inventoryService.Get(loc)
 	if err != nil {
 		SendResponse("Failed to Get inventory", err, http.StatusBadRequest, w)
 		return
@@ -56,7 +62,13 @@ func (h *Inventory) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	item, err := h.inventoryService.GetByID(id)
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	item, err := h.inventoryService.GetByID(id, loc)
 	if err != nil {
 		SendResponse("Failed to get inventory", err, http.StatusInternalServerError, w)
 		return
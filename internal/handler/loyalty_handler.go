@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"frappuccino/internal/service"
+)
+
+type LoyaltyHandler struct {
+	service service.LoyaltyService
+}
+
+func NewLoyaltyHandler(service service.LoyaltyService) *LoyaltyHandler {
+	return &LoyaltyHandler{service: service}
+}
+
+func (l *LoyaltyHandler) Balance(w http.ResponseWriter, r *http.Request) {
+	balance, err := l.service.Balance(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to load loyalty balance", err, loyaltyErrorStatus(err), w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(balance); err != nil {
+		return
+	}
+}
+
+func (l *LoyaltyHandler) Ledger(w http.ResponseWriter, r *http.Request) {
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+	ledger, err := l.service.Ledger(r.PathValue("id"), loc)
+	if err != nil {
+		SendResponse("Failed to load loyalty ledger", err, loyaltyErrorStatus(err), w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(ledger); err != nil {
+		return
+	}
+}
+
+func loyaltyErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidLoyaltyID) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -26,7 +26,7 @@ func (m *MenuHandler) Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.service.Add(request.Menu, request.MenuIngredients); err != nil {
+	if err := m.service.Add(request); err != nil {
 		SendResponse("Failed to add menu item", err, http.StatusInternalServerError, w)
 		return
 	}
@@ -78,7 +78,7 @@ func (m *MenuHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	menuReq.Menu.ID = id
 
-	if err := m.service.Update(menuReq.Menu, menuReq.MenuIngredients); err != nil {
+	if err := m.service.Update(menuReq); err != nil {
 		SendResponse("Failed to add menu item", err, http.StatusInternalServerError, w)
 		return
 	}
@@ -2,10 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"frappuccino/config"
+	"frappuccino/internal/dal"
 	"frappuccino/internal/service"
 	"frappuccino/models"
 )
@@ -26,23 +29,126 @@ func (o *OrderHandler) Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	orderID, _, err := o.OrderService.Add(orderRequest.CustomerName, orderRequest.Orders)
+	dryRun, err := boolQuery(r, "dry_run")
 	if err != nil {
-		SendResponse("Failed to add order", err, http.StatusInternalServerError, w)
+		SendResponse("Invalid dry_run parameter", err, http.StatusBadRequest, w)
+		return
+	}
+	if dryRun {
+		result, err := o.OrderService.DryRun(orderRequest)
+		if err != nil {
+			SendResponse("Failed to check order", err, http.StatusInternalServerError, w)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	placed, err := o.OrderService.Add(orderRequest)
+	if err != nil {
+		SendResponse("Failed to add order", err, orderErrorStatus(err), w)
+		return
+	}
+	response := map[string]interface{}{
+		"message":         "Order placed successfully",
+		"order_id":        placed.OrderID,
+		"status":          placed.Status,
+		"subtotal":        placed.Subtotal,
+		"discount_amount": placed.Discount,
+		"discounts":       placed.Discounts,
+		"tax_amount":      placed.Tax,
+		"taxes":           placed.Taxes,
+		"tip_amount":      placed.Tip,
+		"total_amount":    placed.Total,
+		"points_redeemed": placed.PointsRedeemed,
+	}
+	if placed.PickupAt != nil {
+		response["pickup_at"] = placed.PickupAt
+	}
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Reorder places a new order with the items of an earlier one.
+func (o *OrderHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+
+	placed, err := o.OrderService.Reorder(id)
+	if err != nil {
+		SendResponse("Failed to reorder", err, orderErrorStatus(err), w)
 		return
 	}
 	w.Header().Set("Content-type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":  "Order placed successfully",
-		"order_id": orderID,
+		"message":           "Order placed successfully",
+		"order_id":          placed.OrderID,
+		"status":            placed.Status,
+		"subtotal":          placed.Subtotal,
+		"discount_amount":   placed.Discount,
+		"discounts":         placed.Discounts,
+		"tax_amount":        placed.Tax,
+		"taxes":             placed.Taxes,
+		"tip_amount":        placed.Tip,
+		"total_amount":      placed.Total,
+		"unavailable_items": placed.UnavailableItems,
 	})
 }
 
+// Get lists orders a page at a time. Query parameters: status (comma
+// separated), customerName, startDate, endDate, minTotal, maxTotal, menuItem,
+// sortBy (date, total or id), order (asc or desc), page and pageSize.
 func (o *OrderHandler) Get(w http.ResponseWriter, r *http.Request) {
-	items, err := o.OrderService.GetAll()
+	query := r.URL.Query()
+
+	filter := models.OrderFilter{
+		CustomerName: query.Get("customerName"),
+		StartDate:    query.Get("startDate"),
+		EndDate:      query.Get("endDate"),
+		MenuItem:     query.Get("menuItem"),
+		SortBy:       query.Get("sortBy"),
+		Order:        query.Get("order"),
+	}
+	if status := query.Get("status"); status != "" {
+		filter.Statuses = strings.Split(status, ",")
+	}
+	for name, total := range map[string]**models.Money{"minTotal": &filter.MinTotal, "maxTotal": &filter.MaxTotal} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		amount, err := models.ParseMoney(value)
+		if err != nil {
+			SendResponse("Invalid "+name+" parameter", err, http.StatusBadRequest, w)
+			return
+		}
+		*total = &amount
+	}
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(query.Get("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	filter.Page, filter.PageSize = page, pageSize
+
+	if filter.Location, err = requestLocation(r); err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	items, err := o.OrderService.GetAll(filter)
 	if err != nil {
-		SendResponse("Failed to load orders", err, http.StatusInternalServerError, w)
+		SendResponse("Failed to load orders", err, orderErrorStatus(err), w)
 		return
 	}
 	w.Header().Set("Content-type", "application/json")
@@ -57,7 +163,12 @@ func (o *OrderHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		SendResponse("Error convert string to int", err, http.StatusNotFound, w)
 		return
 	}
-	item, err := o.OrderService.GetByID(id)
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+	item, err := o.OrderService.GetByID(id, loc)
 	if err != nil {
 		SendResponse("Order item not found", err, http.StatusNotFound, w)
 		return
@@ -69,6 +180,30 @@ func (o *OrderHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (o *OrderHandler) History(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Error convert string to int", err, http.StatusNotFound, w)
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	history, err := o.OrderService.History(id, loc)
+	if err != nil {
+		SendResponse("Failed to load order history", err, orderErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(history); err != nil {
+		return
+	}
+}
+
 func (o *OrderHandler) CloseOrder(w http.ResponseWriter, r *http.Request) {
 	config.Logger.Info("Incoming Request Received", "Action", "Update")
 	id, err := strconv.Atoi(r.PathValue("id"))
@@ -77,12 +212,47 @@ func (o *OrderHandler) CloseOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err = o.OrderService.CloseOrder(id); err != nil {
-		SendResponse("Failed to close order", err, http.StatusInternalServerError, w)
+		SendResponse("Failed to close order", err, orderErrorStatus(err), w)
 		return
 	}
 	SendResponse("Successfully updated order", nil, http.StatusOK, w)
 }
 
+func (o *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	config.Logger.Info("Incoming Request Received", "Action", "Cancel")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+	if err = o.OrderService.CancelOrder(id); err != nil {
+		SendResponse("Failed to cancel order", err, orderErrorStatus(err), w)
+		return
+	}
+	SendResponse("Successfully cancelled order", nil, http.StatusOK, w)
+}
+
+func (o *OrderHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	config.Logger.Info("Incoming Request Received", "Action", "UpdateStatus")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+
+	var request models.OrderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendResponse("Failed to decode status", err, http.StatusBadRequest, w)
+		return
+	}
+
+	if err = o.OrderService.UpdateStatus(id, request.Status); err != nil {
+		SendResponse("Failed to update order status", err, orderErrorStatus(err), w)
+		return
+	}
+	SendResponse("Successfully updated order status", nil, http.StatusOK, w)
+}
+
 func (o *OrderHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	config.Logger.Info("Incoming Request Received", "Action", "Delete")
 	id, err := strconv.Atoi(r.PathValue("id"))
@@ -92,7 +262,7 @@ func (o *OrderHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := o.OrderService.Delete(id); err != nil {
-		SendResponse("Failed to delete item", err, http.StatusInternalServerError, w)
+		SendResponse("Failed to delete item", err, orderErrorStatus(err), w)
 		return
 	}
 	SendResponse("Successfully deleted order", nil, http.StatusOK, w)
@@ -104,7 +274,18 @@ func (o *OrderHandler) NumberOfOrders(w http.ResponseWriter, r *http.Request) {
 	startDate := query.Get("startDate")
 	endDate := query.Get("endDate")
 
-	item, err := o.OrderService.NumberOfOrders(StringOrNil(startDate), StringOrNil(endDate))
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	var item interface{}
+	if query.Get("bySize") == "true" {
+		item, err = o.OrderService.NumberOfOrdersBySize(StringOrNil(startDate), StringOrNil(endDate), loc)
+	} else {
+		item, err = o.OrderService.NumberOfOrders(StringOrNil(startDate), StringOrNil(endDate), loc)
+	}
 	if err != nil {
 		SendResponse("Failed to count order", err, http.StatusInternalServerError, w)
 		return
@@ -129,8 +310,8 @@ func (o *OrderHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := o.OrderService.Update(orderRequest.CustomerName, id, orderRequest.Orders); err != nil {
-		SendResponse("Failed to update order", err, http.StatusInternalServerError, w)
+	if err := o.OrderService.Update(id, orderRequest); err != nil {
+		SendResponse("Failed to update order", err, orderErrorStatus(err), w)
 		return
 	}
 	SendResponse("Successfully updated order", nil, http.StatusOK, w)
@@ -144,9 +325,20 @@ func (o *OrderHandler) BulkOrderProcessing(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	req, err := o.OrderService.BatchProcessOrders(request)
+	atomic, err := boolQuery(r, "atomic")
 	if err != nil {
-		SendResponse("Failed bulk order", err, http.StatusInternalServerError, w)
+		SendResponse("Invalid atomic parameter", err, http.StatusBadRequest, w)
+		return
+	}
+	dryRun, err := boolQuery(r, "dry_run")
+	if err != nil {
+		SendResponse("Invalid dry_run parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	req, err := o.OrderService.BatchProcessOrders(request, atomic, dryRun)
+	if err != nil {
+		SendResponse("Failed bulk order", err, orderErrorStatus(err), w)
 		return
 	}
 
@@ -157,6 +349,37 @@ func (o *OrderHandler) BulkOrderProcessing(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// boolQuery reads an optional boolean query parameter, false when absent.
+func boolQuery(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// orderErrorStatus maps order repository errors to HTTP status codes.
+func orderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dal.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dal.ErrOrderNotActive), errors.Is(err, dal.ErrInvalidTransition),
+		errors.Is(err, dal.ErrNotEnoughStock), errors.Is(err, dal.ErrOrderNotPaid),
+		errors.Is(err, dal.ErrNotEnoughPoints), errors.Is(err, service.ErrNothingToReorder),
+		errors.Is(err, dal.ErrOrderConflict), errors.Is(err, dal.ErrOrderOverpaid),
+		errors.Is(err, dal.ErrOrderHasPayments), errors.Is(err, dal.ErrOrderNotDeletable):
+		return http.StatusConflict
+	case errors.Is(err, dal.ErrMenuItemNotFound), errors.Is(err, dal.ErrInvalidModifier),
+		errors.Is(err, dal.ErrInvalidSize), errors.Is(err, dal.ErrInvalidPromoCode),
+		errors.Is(err, dal.ErrCustomerNotFound),
+		errors.Is(err, service.ErrUnknownOrderStatus),
+		errors.Is(err, service.ErrInvalidOrder), errors.Is(err, service.ErrInvalidOrderFilter):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func StringOrNil(s string) interface{} {
 	if s == "" {
 		return nil
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"frappuccino/config"
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+)
+
+type PaymentHandler struct {
+	service service.PaymentService
+}
+
+func NewPaymentHandler(service service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{service: service}
+}
+
+func (p *PaymentHandler) Add(w http.ResponseWriter, r *http.Request) {
+	config.Logger.Info("Incoming Request Received", "Action", "AddPayment")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	var request models.PaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendResponse("Failed to decode payment", err, http.StatusBadRequest, w)
+		return
+	}
+
+	summary, err := p.service.Add(id, request, loc)
+	if err != nil {
+		SendResponse("Failed to record payment", err, paymentErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(summary)
+}
+
+func (p *PaymentHandler) GetByOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Error convert string to int", err, http.StatusNotFound, w)
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	summary, err := p.service.GetByOrder(id, loc)
+	if err != nil {
+		SendResponse("Failed to load payments", err, paymentErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(summary); err != nil {
+		return
+	}
+}
+
+func paymentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidPayment), errors.Is(err, dal.ErrNotEnoughCash):
+		return http.StatusBadRequest
+	case errors.Is(err, dal.ErrGiftCardNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dal.ErrOverpayment), errors.Is(err, dal.ErrNotEnoughGiftBalance):
+		return http.StatusConflict
+	default:
+		return orderErrorStatus(err)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+)
+
+type PromotionHandler struct {
+	service service.PromotionService
+}
+
+func NewPromotionHandler(service service.PromotionService) *PromotionHandler {
+	return &PromotionHandler{service: service}
+}
+
+func (p *PromotionHandler) Add(w http.ResponseWriter, r *http.Request) {
+	var promotion models.Promotion
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		SendResponse("Invalid request payload", err, http.StatusBadRequest, w)
+		return
+	}
+
+	id, err := p.service.Add(promotion)
+	if err != nil {
+		SendResponse("Failed to add promotion", err, promotionErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Promotion added successfully",
+		"promotion_id": id,
+	})
+}
+
+func (p *PromotionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	promotions, err := p.service.GetAll()
+	if err != nil {
+		SendResponse("Failed to load promotions", err, http.StatusInternalServerError, w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(promotions); err != nil {
+		return
+	}
+}
+
+func (p *PromotionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Error convert string to int", err, http.StatusNotFound, w)
+		return
+	}
+	promotion, err := p.service.GetByID(id)
+	if err != nil {
+		SendResponse("Promotion not found", err, promotionErrorStatus(err), w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(promotion); err != nil {
+		return
+	}
+}
+
+func (p *PromotionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+
+	var promotion models.Promotion
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		SendResponse("Invalid request payload", err, http.StatusBadRequest, w)
+		return
+	}
+
+	if err := p.service.Update(id, promotion); err != nil {
+		SendResponse("Failed to update promotion", err, promotionErrorStatus(err), w)
+		return
+	}
+	SendResponse("Promotion updated successfully", nil, http.StatusOK, w)
+}
+
+func (p *PromotionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+	if err := p.service.Delete(id); err != nil {
+		SendResponse("Failed to delete promotion", err, promotionErrorStatus(err), w)
+		return
+	}
+	SendResponse("Promotion deleted successfully", nil, http.StatusNoContent, w)
+}
+
+func promotionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dal.ErrPromotionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidPromotion):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
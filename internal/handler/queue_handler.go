@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"frappuccino/models"
+)
+
+// queueKeepAlive is how often an idle queue stream sends a comment so proxies
+// do not close it.
+const queueKeepAlive = 15 * time.Second
+
+// Queue streams the kitchen queue as Server-Sent Events. It starts with an
+// order_queued event for every open order, then sends every order change as it
+// happens.
+func (o *OrderHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		SendResponse("Streaming is not supported", errors.New("response writer can not flush"), http.StatusInternalServerError, w)
+		return
+	}
+
+	// subscribe before loading the queue so no change falls in between
+	events, unsubscribe := o.OrderService.Subscribe()
+	defer unsubscribe()
+
+	queued, err := o.OrderService.Queue()
+	if err != nil {
+		SendResponse("Failed to load queue", err, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range queued {
+		if err := writeQueueEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(queueKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeQueueEvent(w, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeQueueEvent(w http.ResponseWriter, event models.QueueEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
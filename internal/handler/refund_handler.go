@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"frappuccino/config"
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+)
+
+type RefundHandler struct {
+	service service.RefundService
+}
+
+func NewRefundHandler(service service.RefundService) *RefundHandler {
+	return &RefundHandler{service: service}
+}
+
+func (h *RefundHandler) Add(w http.ResponseWriter, r *http.Request) {
+	config.Logger.Info("Incoming Request Received", "Action", "AddRefund")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	var request models.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendResponse("Failed to decode refund", err, http.StatusBadRequest, w)
+		return
+	}
+
+	refund, err := h.service.Add(id, request, loc)
+	if err != nil {
+		SendResponse("Failed to refund order", err, refundErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(refund)
+}
+
+func (h *RefundHandler) GetByOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Error convert string to int", err, http.StatusNotFound, w)
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	refunds, err := h.service.GetByOrder(id, loc)
+	if err != nil {
+		SendResponse("Failed to load refunds", err, refundErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(refunds); err != nil {
+		return
+	}
+}
+
+func refundErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidRefund), errors.Is(err, dal.ErrOrderItemNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, dal.ErrOrderNotRefundable), errors.Is(err, dal.ErrRefundExceedsOrder):
+		return http.StatusConflict
+	default:
+		return orderErrorStatus(err)
+	}
+}
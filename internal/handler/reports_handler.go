@@ -17,7 +17,7 @@ func NewReportsHandler(service service.ReportsService) *ReportsHandler {
 }
 
 func (m *ReportsHandler) GetTotalSales(w http.ResponseWriter, r *http.Request) {
-	totalSales, err := m.service.TotalPrice()
+	totalSales, err := m.service.TotalPrice(r.URL.Query().Get("byPaymentMethod") == "true")
 	if err != nil {
 		SendResponse("Failed to get total sales", err, http.StatusInternalServerError, w)
 		return
@@ -36,7 +36,7 @@ func (m *ReportsHandler) GetPopularItems(w http.ResponseWriter, r *http.Request)
 	if limit == "" {
 		limit = "10"
 	}
-	popularItems, err := m.service.PopularItems(limit)
+	popularItems, err := m.service.PopularItems(limit, query.Get("bySize") == "true")
 	if err != nil {
 		SendResponse("Failed to get popular items", err, http.StatusInternalServerError, w)
 		return
@@ -93,8 +93,14 @@ func (m *ReportsHandler) OrderedItemsByPeriod(w http.ResponseWriter, r *http.Req
 	month := query.Get("month")
 	year := query.Get("year")
 
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
 	if period == "day" {
-		itemByPeriodMonth, err := m.service.OrderedItemsByPeriodDay(month)
+		itemByPeriodMonth, err := m.service.OrderedItemsByPeriodDay(month, loc)
 		if err != nil {
 			SendResponse("Failed to get item by day", err, http.StatusInternalServerError, w)
 			return
@@ -105,7 +111,7 @@ func (m *ReportsHandler) OrderedItemsByPeriod(w http.ResponseWriter, r *http.Req
 			return
 		}
 	} else if period == "month" {
-		itemByPeriodYear, err := m.service.OrderedItemsByPeriodMonth(year)
+		itemByPeriodYear, err := m.service.OrderedItemsByPeriodMonth(year, loc)
 		if err != nil {
 			SendResponse("Failed to get item by month", err, http.StatusInternalServerError, w)
 			return
@@ -120,3 +126,39 @@ func (m *ReportsHandler) OrderedItemsByPeriod(w http.ResponseWriter, r *http.Req
 		return
 	}
 }
+
+func (m *ReportsHandler) StatusDurations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	loc, err := requestLocation(r)
+	if err != nil {
+		SendResponse("Invalid tz parameter", err, http.StatusBadRequest, w)
+		return
+	}
+
+	durations, err := m.service.StatusDurations(StringOrNil(query.Get("startDate")), StringOrNil(query.Get("endDate")), loc)
+	if err != nil {
+		SendResponse("Failed to get status durations", err, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(durations); err != nil {
+		SendResponse("Failed to encode status durations", err, http.StatusInternalServerError, w)
+		return
+	}
+}
+
+func (m *ReportsHandler) GiftCardLiability(w http.ResponseWriter, r *http.Request) {
+	liability, err := m.service.GiftCardLiability()
+	if err != nil {
+		SendResponse("Failed to get gift card liability", err, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(liability); err != nil {
+		SendResponse("Failed to encode gift card liability", err, http.StatusInternalServerError, w)
+		return
+	}
+}
@@ -2,23 +2,29 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"frappuccino/config"
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
 	flag "frappuccino/models"
 )
 
 func SendResponse(message string, err error, status int, w http.ResponseWriter) {
-	config.Logger.Error(message, err)
+	config.Logger.Error(message, "error", err)
 
 	Response := flag.Error{
 		Message: message,
 		Status:  int64(status),
 	}
+	if status >= 400 && status < 500 {
+		Response.Code = errorCode(err)
+	}
 
 	response, err := json.Marshal(Response)
 	if err != nil {
-		config.Logger.Error("Failed to send response", err)
+		config.Logger.Error("Failed to send response", "error", err)
 		http.Error(w, `{"Error": "Internal Server Error", "Status": 500}`, http.StatusInternalServerError)
 		return
 	}
@@ -26,3 +32,37 @@ func SendResponse(message string, err error, status int, w http.ResponseWriter)
 	w.WriteHeader(status)
 	w.Write(response)
 }
+
+// errorCodes are the errors whose text is returned as the machine-readable
+// Code of a 4xx response. Any other error leaves Code empty, so clients never
+// see a parser or driver message as a code.
+var errorCodes = []error{
+	dal.ErrNotEnoughStock, dal.ErrMenuItemNotFound, dal.ErrOrderNotFound, dal.ErrOrderNotActive,
+	dal.ErrInvalidTransition, dal.ErrOrderConflict, dal.ErrOrderOverpaid, dal.ErrOrderNotDeletable,
+	dal.ErrInvalidModifier, dal.ErrInvalidSize,
+	dal.ErrOverpayment, dal.ErrOrderNotPaid, dal.ErrOrderHasPayments, dal.ErrNotEnoughCash,
+	dal.ErrGiftCardNotFound, dal.ErrGiftCardExists, dal.ErrNotEnoughGiftBalance,
+	dal.ErrOrderNotRefundable, dal.ErrRefundExceedsOrder, dal.ErrOrderItemNotFound,
+	dal.ErrIdempotencyKeyReused, dal.ErrIdempotencyKeyInProgress,
+	dal.ErrTaxRateNotFound, dal.ErrNotEnoughPoints,
+	dal.ErrCustomerNotFound, dal.ErrCustomerExists,
+	dal.ErrPromotionNotFound, dal.ErrInvalidPromoCode,
+	service.ErrInvalidOrder, service.ErrUnknownOrderStatus, service.ErrNothingToReorder, service.ErrInvalidOrderFilter,
+	service.ErrInvalidLoyaltyID, service.ErrInvalidTaxRate, service.ErrInvalidCustomer, service.ErrInvalidRefund,
+	service.ErrInvalidPayment, service.ErrInvalidPromotion, service.ErrInvalidIdempotencyKey, service.ErrInvalidGiftCard,
+}
+
+// errorCode returns the code of the known error err wraps, e.g.
+// insufficient_inventory for "insufficient_inventory: not enough stock ...",
+// or "" for any other error.
+func errorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, known := range errorCodes {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return ""
+}
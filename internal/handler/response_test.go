@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
+)
+
+func TestErrorCode(t *testing.T) {
+	_, atoiErr := strconv.Atoi("abc")
+	jsonErr := json.Unmarshal([]byte("{"), &struct{}{})
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{dal.ErrNotEnoughStock, "insufficient_inventory"},
+		{fmt.Errorf("%w: not enough milk", dal.ErrNotEnoughStock), "insufficient_inventory"},
+		{fmt.Errorf("placing order: %w", fmt.Errorf("%w: no name", service.ErrInvalidOrder)), "invalid_order"},
+		{atoiErr, ""},
+		{jsonErr, ""},
+		{errors.New("pq: duplicate key value violates unique constraint"), ""},
+		{fmt.Errorf("%w: order 1", errors.New("order_not_found")), ""},
+	}
+	for _, test := range tests {
+		if got := errorCode(test.err); got != test.want {
+			t.Errorf("errorCode(%v) = %q, want %q", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"frappuccino/internal/dal"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+)
+
+type TaxHandler struct {
+	service service.TaxService
+}
+
+func NewTaxHandler(service service.TaxService) *TaxHandler {
+	return &TaxHandler{service: service}
+}
+
+func (t *TaxHandler) Add(w http.ResponseWriter, r *http.Request) {
+	var rate models.TaxRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		SendResponse("Invalid request payload", err, http.StatusBadRequest, w)
+		return
+	}
+
+	id, err := t.service.Add(rate)
+	if err != nil {
+		SendResponse("Failed to add tax rate", err, taxErrorStatus(err), w)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Tax rate added successfully",
+		"tax_rate_id": id,
+	})
+}
+
+func (t *TaxHandler) Get(w http.ResponseWriter, r *http.Request) {
+	rates, err := t.service.GetAll()
+	if err != nil {
+		SendResponse("Failed to load tax rates", err, http.StatusInternalServerError, w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(rates); err != nil {
+		return
+	}
+}
+
+func (t *TaxHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Error convert string to int", err, http.StatusNotFound, w)
+		return
+	}
+	rate, err := t.service.GetByID(id)
+	if err != nil {
+		SendResponse("Tax rate not found", err, taxErrorStatus(err), w)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(rate); err != nil {
+		return
+	}
+}
+
+func (t *TaxHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+
+	var rate models.TaxRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		SendResponse("Invalid request payload", err, http.StatusBadRequest, w)
+		return
+	}
+
+	if err := t.service.Update(id, rate); err != nil {
+		SendResponse("Failed to update tax rate", err, taxErrorStatus(err), w)
+		return
+	}
+	SendResponse("Tax rate updated successfully", nil, http.StatusOK, w)
+}
+
+func (t *TaxHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendResponse("Failed to convert id to int", err, http.StatusBadRequest, w)
+		return
+	}
+	if err := t.service.Delete(id); err != nil {
+		SendResponse("Failed to delete tax rate", err, taxErrorStatus(err), w)
+		return
+	}
+	SendResponse("Tax rate deleted successfully", nil, http.StatusNoContent, w)
+}
+
+func taxErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dal.ErrTaxRateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidTaxRate):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
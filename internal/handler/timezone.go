@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"frappuccino/config"
+)
+
+// requestLocation returns the timezone named by the tz query parameter, or the
+// business timezone when it is not given.
+func requestLocation(r *http.Request) (*time.Location, error) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		return config.BusinessLocation, nil
+	}
+	return config.LoadLocation(name)
+}
@@ -4,12 +4,13 @@ import (
 	"database/sql"
 	"net/http"
 
+	"frappuccino/config"
 	"frappuccino/internal/dal"
 	"frappuccino/internal/handler"
 	"frappuccino/internal/service"
 )
 
-func Routes(mux *http.ServeMux, db *sql.DB) {
+func Routes(mux *http.ServeMux, db *sql.DB, orderEvents *service.OrderEvents) {
 	// menu items:
 	menuDal := dal.NewMenuRepo(db)
 	menuService := service.NewFileMenuService(menuDal)
@@ -35,17 +36,92 @@ func Routes(mux *http.ServeMux, db *sql.DB) {
 
 	// orders:
 	orderDal := dal.NewOrderRepo(db)
-	orderService := service.NewOrderService(orderDal)
+	orderService := service.NewOrderService(orderDal, orderEvents)
 	orderHandler := handler.NewOrderHandler(orderService)
+	idempotencyService := service.NewIdempotencyService(dal.NewIdempotencyRepo(db), *config.IdempotencyKeyTTL)
 
-	mux.HandleFunc("POST /orders", orderHandler.Add)
+	mux.HandleFunc("POST /orders", handler.Idempotent(idempotencyService, orderHandler.Add))
 	mux.HandleFunc("GET /orders", orderHandler.Get)
 	mux.HandleFunc("GET /orders/{id}", orderHandler.GetByID)
 	mux.HandleFunc("PUT /orders/{id}", orderHandler.Update)
 	mux.HandleFunc("DELETE /orders/{id}", orderHandler.Delete)
-	mux.HandleFunc("POST /orders/{id}/close", orderHandler.CloseOrder)
+	mux.HandleFunc("POST /orders/{id}/close", handler.Idempotent(idempotencyService, orderHandler.CloseOrder))
+	mux.HandleFunc("POST /orders/{id}/cancel", orderHandler.CancelOrder)
+	mux.HandleFunc("POST /orders/{id}/reorder", orderHandler.Reorder)
+	mux.HandleFunc("POST /orders/{id}/status", orderHandler.UpdateStatus)
+	mux.HandleFunc("GET /orders/{id}/history", orderHandler.History)
 	mux.HandleFunc("GET /orders/numberOfOrderedItems", orderHandler.NumberOfOrders)
-	mux.HandleFunc("POST /orders/batch-process", orderHandler.BulkOrderProcessing)
+	mux.HandleFunc("POST /orders/batch-process", handler.Idempotent(idempotencyService, orderHandler.BulkOrderProcessing))
+
+	// kitchen queue:
+	mux.HandleFunc("GET /queue", orderHandler.Queue)
+
+	// customers:
+	customerDal := dal.NewCustomerRepo(db)
+	customerService := service.NewCustomerService(customerDal, orderDal)
+	customerHandler := handler.NewCustomerHandler(customerService)
+
+	mux.HandleFunc("POST /customers", customerHandler.Add)
+	mux.HandleFunc("GET /customers", customerHandler.Get)
+	mux.HandleFunc("GET /customers/{id}", customerHandler.GetByID)
+	mux.HandleFunc("PUT /customers/{id}", customerHandler.Update)
+	mux.HandleFunc("DELETE /customers/{id}", customerHandler.Delete)
+	mux.HandleFunc("GET /customers/{id}/orders", customerHandler.Orders)
+
+	// loyalty:
+	loyaltyDal := dal.NewLoyaltyRepo(db)
+	loyaltyService := service.NewLoyaltyService(loyaltyDal)
+	loyaltyHandler := handler.NewLoyaltyHandler(loyaltyService)
+
+	mux.HandleFunc("GET /loyalty/{id}", loyaltyHandler.Balance)
+	mux.HandleFunc("GET /loyalty/{id}/ledger", loyaltyHandler.Ledger)
+
+	// payments:
+	paymentDal := dal.NewPaymentRepo(db)
+	paymentService := service.NewPaymentService(paymentDal)
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+
+	mux.HandleFunc("POST /orders/{id}/payments", paymentHandler.Add)
+	mux.HandleFunc("GET /orders/{id}/payments", paymentHandler.GetByOrder)
+
+	// gift cards:
+	giftCardDal := dal.NewGiftCardRepo(db)
+	giftCardService := service.NewGiftCardService(giftCardDal)
+	giftCardHandler := handler.NewGiftCardHandler(giftCardService)
+
+	mux.HandleFunc("POST /gift-cards", giftCardHandler.Issue)
+	mux.HandleFunc("GET /gift-cards/{code}", giftCardHandler.GetByCode)
+	mux.HandleFunc("POST /gift-cards/{code}/top-up", giftCardHandler.TopUp)
+
+	// refunds:
+	refundDal := dal.NewRefundRepo(db)
+	refundService := service.NewRefundService(refundDal)
+	refundHandler := handler.NewRefundHandler(refundService)
+
+	mux.HandleFunc("POST /orders/{id}/refunds", refundHandler.Add)
+	mux.HandleFunc("GET /orders/{id}/refunds", refundHandler.GetByOrder)
+
+	// promotions:
+	promotionDal := dal.NewPromotionRepo(db)
+	promotionService := service.NewPromotionService(promotionDal)
+	promotionHandler := handler.NewPromotionHandler(promotionService)
+
+	mux.HandleFunc("POST /promotions", promotionHandler.Add)
+	mux.HandleFunc("GET /promotions", promotionHandler.Get)
+	mux.HandleFunc("GET /promotions/{id}", promotionHandler.GetByID)
+	mux.HandleFunc("PUT /promotions/{id}", promotionHandler.Update)
+	mux.HandleFunc("DELETE /promotions/{id}", promotionHandler.Delete)
+
+	// tax rates:
+	taxDal := dal.NewTaxRepo(db)
+	taxService := service.NewTaxService(taxDal)
+	taxHandler := handler.NewTaxHandler(taxService)
+
+	mux.HandleFunc("POST /tax-rates", taxHandler.Add)
+	mux.HandleFunc("GET /tax-rates", taxHandler.Get)
+	mux.HandleFunc("GET /tax-rates/{id}", taxHandler.GetByID)
+	mux.HandleFunc("PUT /tax-rates/{id}", taxHandler.Update)
+	mux.HandleFunc("DELETE /tax-rates/{id}", taxHandler.Delete)
 
 	// aggregations:
 	reportsDal := dal.NewReportsRepo(db)
@@ -56,4 +132,6 @@ func Routes(mux *http.ServeMux, db *sql.DB) {
 	mux.HandleFunc("GET /reports/popular-items", reportsHandler.GetPopularItems)
 	mux.HandleFunc("GET /reports/search", reportsHandler.FullTextSearchReport)
 	mux.HandleFunc("GET /reports/orderedItemsByPeriod", reportsHandler.OrderedItemsByPeriod)
+	mux.HandleFunc("GET /reports/status-durations", reportsHandler.StatusDurations)
+	mux.HandleFunc("GET /reports/gift-card-liability", reportsHandler.GiftCardLiability)
 }
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+type CustomerService interface {
+	Add(customer model.Customer) (int, error)
+	GetAll() ([]model.Customer, error)
+	GetByID(id int) (model.Customer, error)
+	Update(id int, customer model.Customer) error
+	Delete(id int) error
+	Orders(id int, loc *time.Location) ([]model.OrderResponse, error)
+}
+
+type Customer struct {
+	repository dal.CustomerRepository
+	orders     dal.OrderRepository
+}
+
+func NewCustomerService(repository dal.CustomerRepository, orders dal.OrderRepository) *Customer {
+	return &Customer{repository: repository, orders: orders}
+}
+
+var ErrInvalidCustomer = errors.New("invalid_customer")
+
+func (c *Customer) Add(customer model.Customer) (int, error) {
+	if err := validateCustomer(customer); err != nil {
+		return 0, err
+	}
+	return c.repository.Add(customer)
+}
+
+func (c *Customer) GetAll() ([]model.Customer, error) {
+	return c.repository.GetAll()
+}
+
+func (c *Customer) GetByID(id int) (model.Customer, error) {
+	return c.repository.GetByID(id)
+}
+
+func (c *Customer) Update(id int, customer model.Customer) error {
+	if id <= 0 {
+		return errors.New("id can not be empty or zero")
+	}
+	if err := validateCustomer(customer); err != nil {
+		return err
+	}
+	return c.repository.Update(id, customer)
+}
+
+func (c *Customer) Delete(id int) error {
+	return c.repository.Delete(id)
+}
+
+// Orders returns the order history of a customer.
+func (c *Customer) Orders(id int, loc *time.Location) ([]model.OrderResponse, error) {
+	if _, err := c.repository.GetByID(id); err != nil {
+		return nil, err
+	}
+	return c.orders.GetByCustomer(id, loc)
+}
+
+func validateCustomer(customer model.Customer) error {
+	if strings.TrimSpace(customer.Name) == "" {
+		return fmt.Errorf("%w: name can not be empty", ErrInvalidCustomer)
+	}
+	if len(customer.Phone) > 32 {
+		return fmt.Errorf("%w: phone is longer than 32 characters", ErrInvalidCustomer)
+	}
+	if customer.Email != "" && !strings.Contains(customer.Email, "@") {
+		return fmt.Errorf("%w: email %q is not valid", ErrInvalidCustomer, customer.Email)
+	}
+	return validateOptions(ErrInvalidCustomer, "preferences", customer.Preferences)
+}
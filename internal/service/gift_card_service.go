@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+type GiftCardService interface {
+	Issue(request model.GiftCardRequest, loc *time.Location) (model.GiftCard, error)
+	GetByCode(code string, loc *time.Location) (model.GiftCard, error)
+	TopUp(code string, request model.GiftCardRequest, loc *time.Location) (model.GiftCard, error)
+}
+
+type GiftCard struct {
+	repository dal.GiftCardRepository
+}
+
+func NewGiftCardService(repository dal.GiftCardRepository) *GiftCard {
+	return &GiftCard{repository: repository}
+}
+
+var ErrInvalidGiftCard = errors.New("invalid_gift_card")
+
+func (g *GiftCard) Issue(request model.GiftCardRequest, loc *time.Location) (model.GiftCard, error) {
+	if len(request.Code) > 32 {
+		return model.GiftCard{}, fmt.Errorf("%w: code is longer than 32 characters", ErrInvalidGiftCard)
+	}
+	if request.Amount <= 0 {
+		return model.GiftCard{}, fmt.Errorf("%w: amount must be greater than 0", ErrInvalidGiftCard)
+	}
+	return g.repository.Issue(request.Code, request.Amount, loc)
+}
+
+func (g *GiftCard) GetByCode(code string, loc *time.Location) (model.GiftCard, error) {
+	return g.repository.GetByCode(code, loc)
+}
+
+func (g *GiftCard) TopUp(code string, request model.GiftCardRequest, loc *time.Location) (model.GiftCard, error) {
+	if request.Amount <= 0 {
+		return model.GiftCard{}, fmt.Errorf("%w: amount must be greater than 0", ErrInvalidGiftCard)
+	}
+	return g.repository.TopUp(code, request.Amount, loc)
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+type IdempotencyService interface {
+	Claim(key string, request []byte) (model.IdempotentResponse, bool, error)
+	Complete(key string, statusCode int, body []byte) error
+	Release(key string) error
+}
+
+type Idempotency struct {
+	repository dal.IdempotencyRepository
+	ttl        time.Duration
+}
+
+func NewIdempotencyService(repository dal.IdempotencyRepository, ttl time.Duration) *Idempotency {
+	return &Idempotency{repository: repository, ttl: ttl}
+}
+
+var ErrInvalidIdempotencyKey = errors.New("invalid_idempotency_key")
+
+// Claim takes the key for a request, identified by a hash of its contents. See
+// dal.Idempotency.Claim.
+func (i *Idempotency) Claim(key string, request []byte) (model.IdempotentResponse, bool, error) {
+	if len(key) > 255 {
+		return model.IdempotentResponse{}, false, fmt.Errorf("%w: key is longer than 255 characters", ErrInvalidIdempotencyKey)
+	}
+	hash := sha256.Sum256(request)
+	return i.repository.Claim(key, hex.EncodeToString(hash[:]), time.Now().Add(-i.ttl))
+}
+
+func (i *Idempotency) Complete(key string, statusCode int, body []byte) error {
+	return i.repository.Complete(key, statusCode, body)
+}
+
+func (i *Idempotency) Release(key string) error {
+	return i.repository.Release(key)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"time"
 
 	"frappuccino/internal/dal"
 	"frappuccino/models"
@@ -9,8 +10,8 @@ import (
 
 type InventoryService interface {
 	Add(inventoryItem models.InventoryItem) error
-	Get() ([]models.InventoryItem, error)
-	GetByID(id int) (models.InventoryItem, error)
+	Get(loc *time.Location) ([]models.InventoryItem, error)
+	GetByID(id int, loc *time.Location) (models.InventoryItem, error)
 	Update(id int, inventoryItem models.InventoryItem) error
 	Delete(id int) error
 	CountInventory(sortBy string, page, pageSize int) (models.CountInventory, error)
@@ -50,19 +51,19 @@ func (s *Inventory) Add(inventoryItem models.InventoryItem) error {
 	return nil
 }
 
-func (s *Inventory) Get() ([]models.InventoryItem, error) {
-	return s.repository.GetAll()
+func (s *Inventory) Get(loc *time.Location) ([]models.InventoryItem, error) {
+	return s.repository.GetAll(loc)
 }
 
-func (s *Inventory) GetByID(id int) (models.InventoryItem, error) {
-	return s.repository.GetByID(id)
+func (s *Inventory) GetByID(id int, loc *time.Location) (models.InventoryItem, error) {
+	return s.repository.GetByID(id, loc)
 }
 
 func (s *Inventory) Update(id int, inventoryItem models.InventoryItem) error {
 	if id <= 0 {
 		return errors.New("id can not be empty and less or equal to zero")
 	}
-	if _, err := s.repository.GetByID(id); err != nil {
+	if _, err := s.repository.GetByID(id, time.UTC); err != nil {
 		return err
 	}
 
@@ -89,7 +90,7 @@ func (s *Inventory) Delete(id int) error {
 	if id <= 0 {
 		return errors.New("id can not be empty and less or equal to zero")
 	}
-	if _, err := s.repository.GetByID(id); err != nil {
+	if _, err := s.repository.GetByID(id, time.UTC); err != nil {
 		return err
 	}
 
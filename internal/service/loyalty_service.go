@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+type LoyaltyService interface {
+	Balance(loyaltyID string) (model.LoyaltyBalance, error)
+	Ledger(loyaltyID string, loc *time.Location) (model.LoyaltyBalance, error)
+}
+
+type Loyalty struct {
+	repository dal.LoyaltyRepository
+}
+
+func NewLoyaltyService(repository dal.LoyaltyRepository) *Loyalty {
+	return &Loyalty{repository: repository}
+}
+
+var ErrInvalidLoyaltyID = errors.New("invalid_loyalty_id")
+
+func (l *Loyalty) Balance(loyaltyID string) (model.LoyaltyBalance, error) {
+	if err := validateLoyaltyID(loyaltyID); err != nil {
+		return model.LoyaltyBalance{}, err
+	}
+	return l.repository.Balance(loyaltyID)
+}
+
+func (l *Loyalty) Ledger(loyaltyID string, loc *time.Location) (model.LoyaltyBalance, error) {
+	if err := validateLoyaltyID(loyaltyID); err != nil {
+		return model.LoyaltyBalance{}, err
+	}
+	return l.repository.Ledger(loyaltyID, loc)
+}
+
+func validateLoyaltyID(loyaltyID string) error {
+	if loyaltyID == "" || len(loyaltyID) > 64 {
+		return fmt.Errorf("%w: loyalty id must be 1 to 64 characters", ErrInvalidLoyaltyID)
+	}
+	return nil
+}
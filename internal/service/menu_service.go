@@ -13,10 +13,10 @@ import (
 )
 
 type MenuService interface {
-	Add(item model.MenuItem, menuIngredients []model.MenuInventory) error
+	Add(menu model.MenuRequest) error
 	Get() ([]model.MenuRequest, error)
 	GetByID(id int) (*model.MenuRequest, error)
-	Update(item model.MenuItem, menuIngredients []model.MenuInventory) error
+	Update(menu model.MenuRequest) error
 	Delete(id int) error
 }
 
@@ -28,8 +28,14 @@ func NewFileMenuService(dataAccess dal.MenuRepository) *Menu {
 	return &Menu{dataAccess: dataAccess}
 }
 
-func (f *Menu) Add(item model.MenuItem, menuIngredients []model.MenuInventory) error {
-	return f.dataAccess.Save(item, menuIngredients)
+func (f *Menu) Add(menu model.MenuRequest) error {
+	if err := validateSizes(menu.Sizes); err != nil {
+		return err
+	}
+	if err := validateModifiers(menu.Modifiers); err != nil {
+		return err
+	}
+	return f.dataAccess.Save(menu)
 }
 
 func (f *Menu) Get() ([]model.MenuRequest, error) {
@@ -45,7 +51,9 @@ func (f *Menu) GetByID(id int) (*model.MenuRequest, error) {
 	return &items, nil
 }
 
-func (f *Menu) Update(item model.MenuItem, menuIngredients []model.MenuInventory) error {
+func (f *Menu) Update(menu model.MenuRequest) error {
+	item, menuIngredients := menu.Menu, menu.MenuIngredients
+
 	if item.ID <= 0 {
 		return errors.New("id can not be empty or zero")
 	}
@@ -76,7 +84,60 @@ func (f *Menu) Update(item model.MenuItem, menuIngredients []model.MenuInventory
 		}
 	}
 
-	return f.dataAccess.Update(item, menuIngredients)
+	if err := validateSizes(menu.Sizes); err != nil {
+		return err
+	}
+	if err := validateModifiers(menu.Modifiers); err != nil {
+		return err
+	}
+
+	return f.dataAccess.Update(menu)
+}
+
+func validateSizes(sizes []model.MenuItemSize) error {
+	seen := make(map[string]bool)
+	for _, size := range sizes {
+		switch size.Size {
+		case model.SizeSmall, model.SizeMedium, model.SizeLarge:
+		default:
+			return fmt.Errorf("unknown size %q", size.Size)
+		}
+		if seen[size.Size] {
+			return fmt.Errorf("size %q is listed twice", size.Size)
+		}
+		seen[size.Size] = true
+
+		if size.Price <= 0 {
+			return errors.New("size price must be greater than 0")
+		}
+		if size.IngredientMultiplier < 0 {
+			return errors.New("ingredient multiplier can not be negative")
+		}
+	}
+	return nil
+}
+
+func validateModifiers(modifiers []model.Modifier) error {
+	seen := make(map[string]bool)
+	for _, modifier := range modifiers {
+		if modifier.Name == "" {
+			return errors.New("modifier name can not be empty")
+		}
+		if seen[modifier.Name] {
+			return fmt.Errorf("modifier %q is listed twice", modifier.Name)
+		}
+		seen[modifier.Name] = true
+
+		for _, ingredient := range modifier.Ingredients {
+			if ingredient.Inventory.Name == "" {
+				return errors.New("inventory name can not be empty")
+			}
+			if ingredient.Quantity == 0 {
+				return errors.New("modifier ingredient quantity can not be 0")
+			}
+		}
+	}
+	return nil
 }
 
 func (f *Menu) Delete(id int) error {
@@ -2,127 +2,471 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"time"
 
+	"frappuccino/config"
 	"frappuccino/internal/dal"
 	model "frappuccino/models"
 )
 
 type OrdersService interface {
-	Add(name string, itemReq []model.OrderItemRequest) (int, []model.InventoryUpdate, error)
-	GetAll() ([]model.OrderResponse, error)
-	GetByID(id int) (model.OrderResponse, error)
-	Update(name string, id int, itemReq []model.OrderItemRequest) error
+	Add(order model.OrderRequest) (model.PlacedOrder, error)
+	GetAll(filter model.OrderFilter) (model.OrderPage, error)
+	GetByID(id int, loc *time.Location) (model.OrderResponse, error)
+	Update(id int, order model.OrderRequest) error
+	Reorder(id int) (model.PlacedOrder, error)
 	CloseOrder(id int) error
+	CancelOrder(id int) error
+	UpdateStatus(id int, status string) error
+	History(id int, loc *time.Location) ([]model.OrderStatusHistory, error)
 	Delete(id int) error
-	NumberOfOrders(startDate, endDate interface{}) (model.NumberOfOrderedItemsResponse, error)
-	BatchProcessOrders(request model.BatchOrderRequest) (model.BatchOrderResponse, error)
+	NumberOfOrders(startDate, endDate interface{}, loc *time.Location) (model.NumberOfOrderedItemsResponse, error)
+	NumberOfOrdersBySize(startDate, endDate interface{}, loc *time.Location) (model.NumberOfOrderedItemsBySizeResponse, error)
+	BatchProcessOrders(request model.BatchOrderRequest, atomic, dryRun bool) (model.BatchOrderResponse, error)
+	DryRun(order model.OrderRequest) (model.BatchOrderResponse, error)
+	Queue() ([]model.QueueEvent, error)
+	Subscribe() (<-chan model.QueueEvent, func())
 }
 
+// Order is the order service. Every change it makes to an order is published
+// to events for the kitchen queue.
 type Order struct {
 	repository dal.OrderRepository
+	events     *OrderEvents
 }
 
-func NewOrderService(dataAccess dal.OrderRepository) *Order {
-	return &Order{repository: dataAccess}
+func NewOrderService(dataAccess dal.OrderRepository, events *OrderEvents) *Order {
+	return &Order{repository: dataAccess, events: events}
 }
 
-func (o *Order) Add(name string, itemReq []model.OrderItemRequest) (int, []model.InventoryUpdate, error) {
-	return o.repository.Add(name, itemReq)
+func (o *Order) Add(order model.OrderRequest) (model.PlacedOrder, error) {
+	if err := validateOrderRequest(order); err != nil {
+		return model.PlacedOrder{}, err
+	}
+	placed, err := o.repository.Add(order)
+	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+	// pre-orders only join the queue when the scheduler releases them
+	if placed.Status == model.StatusPending {
+		publishOrder(o.events, o.repository, model.QueueOrderCreated, placed.OrderID)
+	}
+	return placed, nil
 }
 
-func (o *Order) GetAll() ([]model.OrderResponse, error) {
-	return o.repository.GetAll()
+// queueStatuses are the statuses of the orders shown in the kitchen queue.
+var queueStatuses = []string{model.StatusPending, model.StatusPreparing, model.StatusReady}
+
+// maxQueueSize bounds the open orders sent to a queue client when it connects.
+const maxQueueSize = 500
+
+// Queue returns the open orders, oldest first, as the events a queue client
+// starts from.
+func (o *Order) Queue() ([]model.QueueEvent, error) {
+	page, err := o.repository.GetAll(model.OrderFilter{
+		Statuses: queueStatuses,
+		SortBy:   "date",
+		Order:    "asc",
+		Page:     1,
+		PageSize: maxQueueSize,
+		Location: config.BusinessLocation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]model.QueueEvent, 0, len(page.Data))
+	for _, order := range page.Data {
+		events = append(events, model.NewQueueEvent(model.QueueOrderQueued, order))
+	}
+	return events, nil
 }
 
-func (o *Order) GetByID(id int) (model.OrderResponse, error) {
-	return o.repository.GetByID(id)
+// Subscribe returns the queue events published from now on. See
+// OrderEvents.Subscribe.
+func (o *Order) Subscribe() (<-chan model.QueueEvent, func()) {
+	return o.events.Subscribe()
 }
 
-func (o *Order) Update(name string, id int, itemReq []model.OrderItemRequest) error {
-	return o.repository.Update(name, id, itemReq)
+const maxOrderPageSize = 100
+
+func (o *Order) GetAll(filter model.OrderFilter) (model.OrderPage, error) {
+	for _, status := range filter.Statuses {
+		if !slices.Contains(orderStatuses, status) {
+			return model.OrderPage{}, fmt.Errorf("%w: unknown status %q", ErrInvalidOrderFilter, status)
+		}
+	}
+	for _, date := range []string{filter.StartDate, filter.EndDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return model.OrderPage{}, fmt.Errorf("%w: date %q is not YYYY-MM-DD", ErrInvalidOrderFilter, date)
+		}
+	}
+	if filter.StartDate != "" && filter.EndDate != "" && filter.StartDate > filter.EndDate {
+		return model.OrderPage{}, fmt.Errorf("%w: start date is after end date", ErrInvalidOrderFilter)
+	}
+	if filter.MinTotal != nil && filter.MaxTotal != nil && *filter.MinTotal > *filter.MaxTotal {
+		return model.OrderPage{}, fmt.Errorf("%w: minimum total is greater than maximum total", ErrInvalidOrderFilter)
+	}
+	if filter.SortBy != "" && filter.SortBy != "date" && filter.SortBy != "total" && filter.SortBy != "id" {
+		return model.OrderPage{}, fmt.Errorf("%w: orders can be sorted by date, total or id", ErrInvalidOrderFilter)
+	}
+	if filter.Order != "" && filter.Order != "asc" && filter.Order != "desc" {
+		return model.OrderPage{}, fmt.Errorf("%w: order must be asc or desc", ErrInvalidOrderFilter)
+	}
+	if filter.PageSize > maxOrderPageSize {
+		filter.PageSize = maxOrderPageSize
+	}
+	if filter.Location == nil {
+		filter.Location = config.BusinessLocation
+	}
+	return o.repository.GetAll(filter)
 }
 
-func (o *Order) CloseOrder(id int) error {
-	return o.repository.UpdateStatus(id, "closed")
+func (o *Order) GetByID(id int, loc *time.Location) (model.OrderResponse, error) {
+	return o.repository.GetByID(id, loc)
 }
 
-func (o *Order) Delete(id int) error {
-	order, err := o.repository.GetByID(id)
+func (o *Order) Update(id int, order model.OrderRequest) error {
+	if id <= 0 {
+		return errors.New("id can not be empty or zero")
+	}
+	if err := validateOrderRequest(order); err != nil {
+		return err
+	}
+	if err := o.repository.Update(id, order); err != nil {
+		return err
+	}
+	publishOrder(o.events, o.repository, model.QueueOrderUpdated, id)
+	return nil
+}
+
+// Reorder places a new order with the items of order id at today's menu
+// prices. Items that are no longer on the menu are skipped and listed in the
+// result.
+func (o *Order) Reorder(id int) (model.PlacedOrder, error) {
+	order, unavailable, err := o.repository.ReorderRequest(id)
 	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+	if len(order.Orders) == 0 {
+		return model.PlacedOrder{}, fmt.Errorf("%w: none of the items of order %d can be ordered anymore", ErrNothingToReorder, id)
+	}
+
+	placed, err := o.Add(order)
+	if err != nil {
+		return model.PlacedOrder{}, err
+	}
+	placed.UnavailableItems = unavailable
+	return placed, nil
+}
+
+var (
+	ErrUnknownOrderStatus = errors.New("unknown_order_status")
+	ErrInvalidOrder       = errors.New("invalid_order")
+	ErrNothingToReorder   = errors.New("nothing_to_reorder")
+	ErrInvalidOrderFilter = errors.New("invalid_order_filter")
+)
+
+var orderStatuses = []string{
+	model.StatusScheduled, model.StatusPending, model.StatusPreparing, model.StatusReady,
+	model.StatusPickedUp, model.StatusClosed, model.StatusCancelled,
+}
+
+var itemSizes = []string{model.SizeSmall, model.SizeMedium, model.SizeLarge}
+
+const (
+	maxOptionKeys    = 20
+	maxOptionKeyLen  = 50
+	maxOptionTextLen = 200
+)
+
+func validateOrderRequest(order model.OrderRequest) error {
+	if order.CustomerName == "" && order.CustomerID == 0 {
+		return fmt.Errorf("%w: customer name can not be empty", ErrInvalidOrder)
+	}
+	if len(order.Orders) == 0 {
+		return fmt.Errorf("%w: order must contain at least one item", ErrInvalidOrder)
+	}
+	if err := validateOptions(ErrInvalidOrder, "special instructions", order.SpecialInstructions); err != nil {
 		return err
 	}
-	if order.OrderID != id {
-		return errors.New("order ID not match")
+	if len(order.PromoCode) > 50 {
+		return fmt.Errorf("%w: promo code is longer than 50 characters", ErrInvalidOrder)
+	}
+	if order.Tip < 0 {
+		return fmt.Errorf("%w: tip can not be negative", ErrInvalidOrder)
+	}
+	if order.PickupAt != nil && !order.PickupAt.After(time.Now()) {
+		return fmt.Errorf("%w: pickup time must be in the future", ErrInvalidOrder)
+	}
+	if len(order.LoyaltyID) > 64 {
+		return fmt.Errorf("%w: loyalty id is longer than 64 characters", ErrInvalidOrder)
+	}
+	if order.RedeemPoints < 0 {
+		return fmt.Errorf("%w: redeemed points can not be negative", ErrInvalidOrder)
+	}
+	if order.RedeemPoints > 0 && order.LoyaltyID == "" {
+		return fmt.Errorf("%w: loyalty id is required to redeem points", ErrInvalidOrder)
+	}
+
+	for _, item := range order.Orders {
+		if item.MenuItemID == "" {
+			return fmt.Errorf("%w: product id can not be empty", ErrInvalidOrder)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity of %s must be greater than 0", ErrInvalidOrder, item.MenuItemID)
+		}
+		if item.Size != "" && !slices.Contains(itemSizes, item.Size) {
+			return fmt.Errorf("%w: unknown size %q of %s", ErrInvalidOrder, item.Size, item.MenuItemID)
+		}
+		for _, modifier := range item.Modifiers {
+			if modifier == "" {
+				return fmt.Errorf("%w: modifier of %s can not be empty", ErrInvalidOrder, item.MenuItemID)
+			}
+		}
+		if err := validateOptions(ErrInvalidOrder, "customizations of "+item.MenuItemID, item.Customizations); err != nil {
+			return err
+		}
 	}
-	return o.repository.Delete(id)
+	return nil
 }
 
-func (o *Order) NumberOfOrders(startDate, endDate interface{}) (model.NumberOfOrderedItemsResponse, error) {
-	return o.repository.NumberOfOrders(startDate, endDate)
+// validateOptions checks a customizations, special instructions or
+// preferences document and reports problems as kind. Values must be strings,
+// numbers, booleans or lists of those, so the kitchen never has to interpret
+// nested structures.
+func validateOptions(kind error, field string, options map[string]interface{}) error {
+	if len(options) > maxOptionKeys {
+		return fmt.Errorf("%w: %s can not have more than %d entries", kind, field, maxOptionKeys)
+	}
+
+	for key, value := range options {
+		if key == "" || len(key) > maxOptionKeyLen {
+			return fmt.Errorf("%w: %s has an empty or too long key", kind, field)
+		}
+
+		values, isList := value.([]interface{})
+		if !isList {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				if len(v) > maxOptionTextLen {
+					return fmt.Errorf("%w: %s value of %q is too long", kind, field, key)
+				}
+			case float64, bool:
+			default:
+				return fmt.Errorf("%w: %s value of %q must be a string, number or boolean", kind, field, key)
+			}
+		}
+	}
+	return nil
 }
 
-func (s *Order) BatchProcessOrders(request model.BatchOrderRequest) (model.BatchOrderResponse, error) {
-	var (
-		processedOrders  []model.ProcessedOrder
-		totalRevenue     float64
-		accepted         int
-		rejected         int
-		inventoryUpdates []model.InventoryUpdate
-	)
+// orderTransitions lists the statuses an order may move to from each status.
+var orderTransitions = map[string][]string{
+	model.StatusScheduled: {model.StatusPending, model.StatusCancelled},
+	model.StatusPending:   {model.StatusPreparing, model.StatusCancelled},
+	model.StatusPreparing: {model.StatusReady, model.StatusCancelled},
+	model.StatusReady:     {model.StatusPickedUp, model.StatusClosed, model.StatusCancelled},
+	model.StatusPickedUp:  {model.StatusClosed},
+}
+
+// previousStatuses returns the statuses from which an order may move to status.
+func previousStatuses(status string) []string {
+	var from []string
+	for current, next := range orderTransitions {
+		if slices.Contains(next, status) {
+			from = append(from, current)
+		}
+	}
+	return from
+}
+
+func (o *Order) CloseOrder(id int) error {
+	return o.UpdateStatus(id, model.StatusClosed)
+}
+
+func (o *Order) CancelOrder(id int) error {
+	return o.UpdateStatus(id, model.StatusCancelled)
+}
+
+func (o *Order) UpdateStatus(id int, status string) error {
+	if id <= 0 {
+		return errors.New("id can not be empty or zero")
+	}
+
+	from := previousStatuses(status)
+	if len(from) == 0 {
+		if _, ok := orderTransitions[status]; ok {
+			return fmt.Errorf("%w: order can not move back to %s", dal.ErrInvalidTransition, status)
+		}
+		return fmt.Errorf("%w: %q", ErrUnknownOrderStatus, status)
+	}
+
+	var err error
+	if status == model.StatusCancelled {
+		err = o.repository.Cancel(id, from)
+	} else {
+		err = o.repository.UpdateStatus(id, from, status)
+	}
+	if err != nil {
+		return err
+	}
+	publishOrder(o.events, o.repository, model.QueueStatusChange, id)
+	return nil
+}
+
+// History returns the status timeline of an order. Each entry carries the time
+// the order spent in that status; the current status counts up to now unless
+// the order is already closed or cancelled.
+func (o *Order) History(id int, loc *time.Location) ([]model.OrderStatusHistory, error) {
+	history, err := o.repository.History(id, loc)
+	if err != nil {
+		return nil, err
+	}
 
-	var allItemNames []string
-	for _, order := range request.Orders {
-		for _, item := range order.Items {
-			allItemNames = append(allItemNames, item.MenuItemName)
+	for i := range history {
+		switch {
+		case i+1 < len(history):
+			history[i].TimeInState = history[i+1].ChangedAt.Sub(history[i].ChangedAt).Seconds()
+		case history[i].Status != model.StatusClosed && history[i].Status != model.StatusCancelled:
+			history[i].TimeInState = time.Since(history[i].ChangedAt).Seconds()
 		}
 	}
+	return history, nil
+}
 
-	priceMap, err := s.repository.GetPriceMap(allItemNames)
+func (o *Order) Delete(id int) error {
+	order, err := o.repository.GetByID(id, config.BusinessLocation)
 	if err != nil {
-		return model.BatchOrderResponse{}, err
+		return err
 	}
+	if order.OrderID != id {
+		return errors.New("order ID not match")
+	}
+	if err := o.repository.Delete(id); err != nil {
+		return err
+	}
+	if o.events != nil {
+		o.events.Publish(model.QueueEvent{Type: model.QueueOrderDeleted, OrderID: id})
+	}
+	return nil
+}
+
+func (o *Order) NumberOfOrders(startDate, endDate interface{}, loc *time.Location) (model.NumberOfOrderedItemsResponse, error) {
+	return o.repository.NumberOfOrders(startDate, endDate, loc)
+}
+
+func (o *Order) NumberOfOrdersBySize(startDate, endDate interface{}, loc *time.Location) (model.NumberOfOrderedItemsBySizeResponse, error) {
+	return o.repository.NumberOfOrdersBySize(startDate, endDate, loc)
+}
 
-	for _, order := range request.Orders {
-		mappedItems := mapToStandardItemReq(order.Items)
+// BatchProcessOrders places every order of a batch and reports on each. Orders
+// normally succeed or fail on their own; with atomic the batch runs in one
+// transaction and a single rejection rolls back the whole batch, the orders
+// that would have been accepted being reported as batch_rolled_back. With
+// dryRun the batch goes through the same steps but nothing is kept.
+func (s *Order) BatchProcessOrders(request model.BatchOrderRequest, atomic, dryRun bool) (model.BatchOrderResponse, error) {
+	orders := make([]model.OrderRequest, len(request.Orders))
+	for i, order := range request.Orders {
+		orders[i] = model.OrderRequest{
+			CustomerID:          order.CustomerID,
+			CustomerName:        order.CustomerName,
+			SpecialInstructions: order.SpecialInstructions,
+			PromoCode:           order.PromoCode,
+			Tip:                 order.Tip,
+			LoyaltyID:           order.LoyaltyID,
+			RedeemPoints:        order.RedeemPoints,
+			Orders:              mapToStandardItemReq(order.Items),
+		}
+	}
+	return s.processOrders(orders, atomic, dryRun)
+}
 
-		orderID, updates, err := s.Add(order.CustomerName, mappedItems)
-		if err != nil {
-			status := "rejected"
-			reason := "unknown_error"
+// DryRun checks a single order the way Add would place it without keeping
+// anything, and reports on it like a batch of one.
+func (s *Order) DryRun(order model.OrderRequest) (model.BatchOrderResponse, error) {
+	return s.processOrders([]model.OrderRequest{order}, false, true)
+}
 
-			if errors.Is(err, dal.ErrNotEnoughStock) {
-				reason = "insufficient_inventory"
-			} else if errors.Is(err, dal.ErrMenuItemNotFound) {
-				reason = "menu_item_not_found"
+func (s *Order) processOrders(orders []model.OrderRequest, atomic, dryRun bool) (model.BatchOrderResponse, error) {
+	placed := make([]model.PlacedOrder, len(orders))
+	errs := make([]error, len(orders))
+	if atomic || dryRun {
+		var err error
+		if placed, errs, err = s.addInOneTx(orders, !dryRun); err != nil {
+			return model.BatchOrderResponse{}, err
+		}
+	} else {
+		for i, order := range orders {
+			placed[i], errs[i] = s.Add(order)
+		}
+	}
+	rolledBack := atomic && slices.ContainsFunc(errs, func(err error) bool { return err != nil })
+	if atomic && !dryRun && !rolledBack {
+		// orders placed one by one through Add are published there
+		for _, order := range placed {
+			if order.Status == model.StatusPending {
+				publishOrder(s.events, s.repository, model.QueueOrderCreated, order.OrderID)
 			}
+		}
+	}
 
-			processedOrders = append(processedOrders, model.ProcessedOrder{
+	var (
+		processedOrders  []model.ProcessedOrder
+		totalRevenue     model.Money
+		accepted         int
+		rejected         int
+		inventoryUpdates []model.InventoryUpdate
+	)
+
+	for i, order := range orders {
+		if errs[i] != nil || rolledBack {
+			processed := model.ProcessedOrder{
 				CustomerName: order.CustomerName,
-				Status:       status,
-				Reason:       reason,
-			})
+				Status:       "rejected",
+				Reason:       "batch_rolled_back",
+			}
+			if errs[i] != nil {
+				processed.Reason = batchRejectReason(errs[i])
+				if processed.Reason != "unknown_error" {
+					processed.Detail = errs[i].Error()
+				}
+			}
+			processedOrders = append(processedOrders, processed)
 			rejected++
 			continue
 		}
 
-		total := calculateTotal(order.Items, priceMap)
-		totalRevenue += total
+		totalRevenue += placed[i].Total
 		accepted++
 
-		processedOrders = append(processedOrders, model.ProcessedOrder{
-			OrderID:      orderID,
+		processed := model.ProcessedOrder{
+			OrderID:      placed[i].OrderID,
 			CustomerName: order.CustomerName,
 			Status:       "accepted",
-			Total:        total,
-		})
+			Total:        placed[i].Total,
+		}
+		if dryRun {
+			processed.OrderID = 0
+		}
+		processedOrders = append(processedOrders, processed)
 
-		inventoryUpdates = append(inventoryUpdates, updates...)
+		inventoryUpdates = append(inventoryUpdates, placed[i].InventoryUpdates...)
 	}
 
 	return model.BatchOrderResponse{
+		DryRun:          dryRun,
 		ProcessedOrders: processedOrders,
 		Summary: model.BatchSummary{
-			TotalOrders:      len(request.Orders),
+			TotalOrders:      len(orders),
 			Accepted:         accepted,
 			Rejected:         rejected,
 			TotalRevenue:     totalRevenue,
@@ -131,23 +475,71 @@ func (s *Order) BatchProcessOrders(request model.BatchOrderRequest) (model.Batch
 	}, nil
 }
 
+// addInOneTx validates the orders and places the valid ones in one
+// transaction. It is committed only with commit set and when every order is
+// valid and placed. Without commit, or with an invalid order, the valid ones
+// are only previewed, which writes and locks nothing.
+func (s *Order) addInOneTx(orders []model.OrderRequest, commit bool) ([]model.PlacedOrder, []error, error) {
+	placed := make([]model.PlacedOrder, len(orders))
+	errs := make([]error, len(orders))
+
+	var valid []int
+	var batch []model.OrderRequest
+	for i, order := range orders {
+		if errs[i] = validateOrderRequest(order); errs[i] == nil {
+			valid = append(valid, i)
+			batch = append(batch, order)
+		}
+	}
+
+	addBatch := s.repository.AddBatch
+	if !commit || len(batch) != len(orders) {
+		addBatch = s.repository.PreviewBatch
+	}
+	batchPlaced, batchErrs, err := addBatch(batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	for j, i := range valid {
+		placed[i], errs[i] = batchPlaced[j], batchErrs[j]
+	}
+	return placed, errs, nil
+}
+
+// batchRejectReason names the reason an order of a batch was rejected.
+func batchRejectReason(err error) string {
+	switch {
+	case errors.Is(err, dal.ErrNotEnoughStock):
+		return "insufficient_inventory"
+	case errors.Is(err, dal.ErrMenuItemNotFound):
+		return "menu_item_not_found"
+	case errors.Is(err, dal.ErrInvalidModifier):
+		return "invalid_modifier"
+	case errors.Is(err, dal.ErrInvalidSize):
+		return "invalid_size"
+	case errors.Is(err, dal.ErrInvalidPromoCode):
+		return "invalid_promo_code"
+	case errors.Is(err, dal.ErrCustomerNotFound):
+		return "customer_not_found"
+	case errors.Is(err, dal.ErrNotEnoughPoints):
+		return "insufficient_points"
+	case errors.Is(err, ErrInvalidOrder):
+		return "invalid_order"
+	default:
+		return "unknown_error"
+	}
+}
+
 func mapToStandardItemReq(batchItems []model.OrderItemRequestBatch) []model.OrderItemRequest {
 	var result []model.OrderItemRequest
 	for _, item := range batchItems {
 		result = append(result, model.OrderItemRequest{
-			MenuItemID: item.MenuItemName,
-			Quantity:   item.Quantity,
+			MenuItemID:     item.MenuItemName,
+			Quantity:       item.Quantity,
+			Size:           item.Size,
+			Modifiers:      item.Modifiers,
+			Customizations: item.Customizations,
 		})
 	}
 	return result
 }
-
-func calculateTotal(items []model.OrderItemRequestBatch, priceMap map[string]float64) float64 {
-	total := 0.0
-	for _, item := range items {
-		if price, ok := priceMap[item.MenuItemName]; ok {
-			total += price * float64(item.Quantity)
-		}
-	}
-	return total
-}
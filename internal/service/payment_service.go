@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+type PaymentService interface {
+	Add(orderID int, request model.PaymentRequest, loc *time.Location) (model.PaymentSummary, error)
+	GetByOrder(orderID int, loc *time.Location) (model.PaymentSummary, error)
+}
+
+type Payment struct {
+	repository dal.PaymentRepository
+}
+
+func NewPaymentService(repository dal.PaymentRepository) *Payment {
+	return &Payment{repository: repository}
+}
+
+var ErrInvalidPayment = errors.New("invalid_payment")
+
+func (p *Payment) Add(orderID int, request model.PaymentRequest, loc *time.Location) (model.PaymentSummary, error) {
+	if orderID <= 0 {
+		return model.PaymentSummary{}, errors.New("id can not be empty or zero")
+	}
+	if len(request.Payments) == 0 {
+		return model.PaymentSummary{}, fmt.Errorf("%w: at least one payment is required", ErrInvalidPayment)
+	}
+
+	for _, tender := range request.Payments {
+		switch tender.Method {
+		case model.PaymentCash, model.PaymentCard, model.PaymentMobile, model.PaymentGiftCard:
+		default:
+			return model.PaymentSummary{}, fmt.Errorf("%w: unknown payment method %q", ErrInvalidPayment, tender.Method)
+		}
+		if tender.Amount < 0 || tender.Tendered < 0 {
+			return model.PaymentSummary{}, fmt.Errorf("%w: amounts can not be negative", ErrInvalidPayment)
+		}
+		if tender.Method != model.PaymentCash && tender.Tendered != 0 {
+			return model.PaymentSummary{}, fmt.Errorf("%w: tendered is only used for cash", ErrInvalidPayment)
+		}
+		if (tender.Method == model.PaymentGiftCard) != (tender.GiftCardCode != "") {
+			return model.PaymentSummary{}, fmt.Errorf("%w: a gift card code is required for, and only for, gift card payments", ErrInvalidPayment)
+		}
+	}
+
+	return p.repository.Add(orderID, request.Payments, loc)
+}
+
+func (p *Payment) GetByOrder(orderID int, loc *time.Location) (model.PaymentSummary, error) {
+	return p.repository.GetByOrder(orderID, loc)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+type PromotionService interface {
+	Add(promotion model.Promotion) (int, error)
+	GetAll() ([]model.Promotion, error)
+	GetByID(id int) (model.Promotion, error)
+	Update(id int, promotion model.Promotion) error
+	Delete(id int) error
+}
+
+type Promotion struct {
+	repository dal.PromotionRepository
+}
+
+func NewPromotionService(repository dal.PromotionRepository) *Promotion {
+	return &Promotion{repository: repository}
+}
+
+var ErrInvalidPromotion = errors.New("invalid_promotion")
+
+func (p *Promotion) Add(promotion model.Promotion) (int, error) {
+	if err := validatePromotion(promotion); err != nil {
+		return 0, err
+	}
+	return p.repository.Add(promotion)
+}
+
+func (p *Promotion) GetAll() ([]model.Promotion, error) {
+	return p.repository.GetAll()
+}
+
+func (p *Promotion) GetByID(id int) (model.Promotion, error) {
+	return p.repository.GetByID(id)
+}
+
+func (p *Promotion) Update(id int, promotion model.Promotion) error {
+	if id <= 0 {
+		return errors.New("id can not be empty or zero")
+	}
+	if err := validatePromotion(promotion); err != nil {
+		return err
+	}
+	return p.repository.Update(id, promotion)
+}
+
+func (p *Promotion) Delete(id int) error {
+	return p.repository.Delete(id)
+}
+
+func validatePromotion(promotion model.Promotion) error {
+	if promotion.Name == "" {
+		return fmt.Errorf("%w: name can not be empty", ErrInvalidPromotion)
+	}
+	if len(promotion.Code) > 50 {
+		return fmt.Errorf("%w: code is longer than 50 characters", ErrInvalidPromotion)
+	}
+
+	switch promotion.DiscountType {
+	case model.DiscountPercentage:
+		if promotion.Percent <= 0 || promotion.Percent > 100 {
+			return fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidPromotion)
+		}
+	case model.DiscountFixed:
+		if promotion.Amount <= 0 {
+			return fmt.Errorf("%w: fixed discount must be greater than 0", ErrInvalidPromotion)
+		}
+	default:
+		return fmt.Errorf("%w: unknown discount type %q", ErrInvalidPromotion, promotion.DiscountType)
+	}
+
+	switch promotion.Scope {
+	case model.ScopeOrder:
+	case model.ScopeTag:
+		if promotion.Tag == "" {
+			return fmt.Errorf("%w: tag promotions need a tag", ErrInvalidPromotion)
+		}
+	case model.ScopeItem:
+		if promotion.MenuItemID <= 0 {
+			return fmt.Errorf("%w: item promotions need a menu_item_id", ErrInvalidPromotion)
+		}
+	default:
+		return fmt.Errorf("%w: unknown scope %q", ErrInvalidPromotion, promotion.Scope)
+	}
+
+	if promotion.UsageLimit < 0 {
+		return fmt.Errorf("%w: usage limit can not be negative", ErrInvalidPromotion)
+	}
+	if promotion.ValidFrom != nil && promotion.ValidUntil != nil && !promotion.ValidUntil.After(*promotion.ValidFrom) {
+		return fmt.Errorf("%w: valid_until must be after valid_from", ErrInvalidPromotion)
+	}
+	return nil
+}
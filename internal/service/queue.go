@@ -0,0 +1,79 @@
+package service
+
+import (
+	"sync"
+
+	"frappuccino/config"
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+// queueBufferSize is how many events a subscriber may fall behind before
+// further events are dropped for it.
+const queueBufferSize = 64
+
+// OrderEvents fans kitchen queue events out to every subscriber. Publishing
+// never blocks: a subscriber that does not keep up misses events.
+type OrderEvents struct {
+	mu          sync.Mutex
+	subscribers map[chan model.QueueEvent]struct{}
+}
+
+func NewOrderEvents() *OrderEvents {
+	return &OrderEvents{subscribers: make(map[chan model.QueueEvent]struct{})}
+}
+
+// Subscribe returns a channel of the events published from now on and a
+// function that ends the subscription and closes the channel.
+func (e *OrderEvents) Subscribe() (<-chan model.QueueEvent, func()) {
+	events := make(chan model.QueueEvent, queueBufferSize)
+
+	e.mu.Lock()
+	e.subscribers[events] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subscribers, events)
+			e.mu.Unlock()
+			close(events)
+		})
+	}
+}
+
+// HasSubscribers reports whether any queue client is listening.
+func (e *OrderEvents) HasSubscribers() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.subscribers) > 0
+}
+
+func (e *OrderEvents) Publish(event model.QueueEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for events := range e.subscribers {
+		select {
+		case events <- event:
+		default:
+			config.Logger.Warn("Kitchen queue subscriber is behind, dropping event", "order_id", event.OrderID, "type", event.Type)
+		}
+	}
+}
+
+// publishOrder loads an order and publishes its current state. The order is
+// not loaded when nobody listens. Failing to load it is logged rather than
+// returned, since the change itself has been made.
+func publishOrder(events *OrderEvents, repository dal.OrderRepository, eventType string, id int) {
+	if events == nil || !events.HasSubscribers() {
+		return
+	}
+	order, err := repository.GetByID(id, config.BusinessLocation)
+	if err != nil {
+		config.Logger.Warn("Failed to load order for kitchen queue", "order_id", id, "error", err)
+		return
+	}
+	events.Publish(model.NewQueueEvent(eventType, order))
+}
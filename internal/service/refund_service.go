@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+type RefundService interface {
+	Add(orderID int, request model.RefundRequest, loc *time.Location) (model.Refund, error)
+	GetByOrder(orderID int, loc *time.Location) ([]model.Refund, error)
+}
+
+type Refund struct {
+	repository dal.RefundRepository
+}
+
+func NewRefundService(repository dal.RefundRepository) *Refund {
+	return &Refund{repository: repository}
+}
+
+var ErrInvalidRefund = errors.New("invalid_refund")
+
+func (r *Refund) Add(orderID int, request model.RefundRequest, loc *time.Location) (model.Refund, error) {
+	if orderID <= 0 {
+		return model.Refund{}, errors.New("id can not be empty or zero")
+	}
+
+	switch request.Reason {
+	case model.RefundCustomerComplaint, model.RefundWrongItem, model.RefundQualityIssue, model.RefundNotMade, model.RefundOther:
+	default:
+		return model.Refund{}, fmt.Errorf("%w: unknown refund reason %q", ErrInvalidRefund, request.Reason)
+	}
+	if len(request.Note) > 500 {
+		return model.Refund{}, fmt.Errorf("%w: note is longer than 500 characters", ErrInvalidRefund)
+	}
+
+	seen := make(map[int]bool, len(request.Items))
+	for _, item := range request.Items {
+		if item.OrderItemID <= 0 || item.Quantity <= 0 {
+			return model.Refund{}, fmt.Errorf("%w: every item needs an order_item_id and a positive quantity", ErrInvalidRefund)
+		}
+		if seen[item.OrderItemID] {
+			return model.Refund{}, fmt.Errorf("%w: item %d is listed twice", ErrInvalidRefund, item.OrderItemID)
+		}
+		seen[item.OrderItemID] = true
+	}
+
+	return r.repository.Add(orderID, request, loc)
+}
+
+func (r *Refund) GetByOrder(orderID int, loc *time.Location) ([]model.Refund, error) {
+	return r.repository.GetByOrder(orderID, loc)
+}
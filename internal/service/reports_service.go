@@ -3,17 +3,20 @@ package service
 import (
 	"errors"
 	"strconv"
+	"time"
 
 	dal "frappuccino/internal/dal"
 	model "frappuccino/models"
 )
 
 type ReportsService interface {
-	TotalPrice() (model.TotalSalesStruct, error)
-	PopularItems(limit string) ([]model.PopularItem, error)
+	TotalPrice(byPaymentMethod bool) (model.TotalSalesStruct, error)
+	PopularItems(limit string, bySize bool) ([]model.PopularItem, error)
 	FullTextSearchReport(q, minPrice, maxPrice string, filterMap map[string]bool) (model.SearchResponse, error)
-	OrderedItemsByPeriodDay(month string) (model.ItemByPeriodMonth, error)
-	OrderedItemsByPeriodMonth(year string) (model.ItemByPeriodYear, error)
+	OrderedItemsByPeriodDay(month string, loc *time.Location) (model.ItemByPeriodMonth, error)
+	OrderedItemsByPeriodMonth(year string, loc *time.Location) (model.ItemByPeriodYear, error)
+	StatusDurations(startDate, endDate interface{}, loc *time.Location) ([]model.StatusDuration, error)
+	GiftCardLiability() (model.GiftCardLiability, error)
 }
 
 type FileReportsService struct {
@@ -24,12 +27,12 @@ func NewFileReportsService(repository dal.ReportsDalInterface) *FileReportsServi
 	return &FileReportsService{repository: repository}
 }
 
-func (f *FileReportsService) TotalPrice() (model.TotalSalesStruct, error) {
-	return f.repository.TotalPrice()
+func (f *FileReportsService) TotalPrice(byPaymentMethod bool) (model.TotalSalesStruct, error) {
+	return f.repository.TotalPrice(byPaymentMethod)
 }
 
-func (f *FileReportsService) PopularItems(limit string) ([]model.PopularItem, error) {
-	return f.repository.PopularItems(limit)
+func (f *FileReportsService) PopularItems(limit string, bySize bool) ([]model.PopularItem, error) {
+	return f.repository.PopularItems(limit, bySize)
 }
 
 func (f *FileReportsService) FullTextSearchReport(q, minPrice, maxPrice string, filterMap map[string]bool) (model.SearchResponse, error) {
@@ -64,12 +67,12 @@ func (f *FileReportsService) FullTextSearchReport(q, minPrice, maxPrice string,
 	return searchResponse, nil
 }
 
-func (f *FileReportsService) OrderedItemsByPeriodDay(month string) (model.ItemByPeriodMonth, error) {
+func (f *FileReportsService) OrderedItemsByPeriodDay(month string, loc *time.Location) (model.ItemByPeriodMonth, error) {
 	monthInt, ok := checkMonth(month)
 	if !ok {
 		return model.ItemByPeriodMonth{}, errors.New("write month correctly")
 	}
-	return f.repository.OrderedItemsByPeriodDay(monthInt)
+	return f.repository.OrderedItemsByPeriodDay(monthInt, loc)
 }
 
 func checkMonth(monthCheck string) (int, bool) {
@@ -92,10 +95,18 @@ func checkMonth(monthCheck string) (int, bool) {
 	return month, ok
 }
 
-func (f *FileReportsService) OrderedItemsByPeriodMonth(year string) (model.ItemByPeriodYear, error) {
+func (f *FileReportsService) OrderedItemsByPeriodMonth(year string, loc *time.Location) (model.ItemByPeriodYear, error) {
 	yearInt, err := strconv.Atoi(year)
 	if err != nil {
 		return model.ItemByPeriodYear{}, err
 	}
-	return f.repository.OrderedItemsByPeriodMonth(yearInt)
+	return f.repository.OrderedItemsByPeriodMonth(yearInt, loc)
+}
+
+func (f *FileReportsService) StatusDurations(startDate, endDate interface{}, loc *time.Location) ([]model.StatusDuration, error) {
+	return f.repository.StatusDurations(startDate, endDate, loc)
+}
+
+func (f *FileReportsService) GiftCardLiability() (model.GiftCardLiability, error) {
+	return f.repository.GiftCardLiability()
 }
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"frappuccino/config"
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+// Scheduler sends scheduled orders to the queue once their pickup time is
+// within the lead time, and flags the ones that can no longer be made. Queued
+// orders are published to events like any other status change.
+type Scheduler struct {
+	repository dal.OrderRepository
+	events     *OrderEvents
+	interval   time.Duration
+	leadTime   time.Duration
+}
+
+func NewScheduler(repository dal.OrderRepository, events *OrderEvents, interval, leadTime time.Duration) *Scheduler {
+	return &Scheduler{repository: repository, events: events, interval: interval, leadTime: leadTime}
+}
+
+// Run checks the scheduled orders every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick() {
+	flagged, err := s.repository.FlagUnfulfillable()
+	if err != nil {
+		config.Logger.Error("Failed to check scheduled orders", "error", err)
+		return
+	}
+	for id, issue := range flagged {
+		if issue == "" {
+			config.Logger.Info("Scheduled order can be fulfilled again", "order_id", id)
+		} else {
+			config.Logger.Warn("Scheduled order can not be fulfilled", "order_id", id, "issue", issue)
+		}
+	}
+
+	due, err := s.repository.DueScheduled(time.Now().Add(s.leadTime))
+	if err != nil {
+		config.Logger.Error("Failed to load due scheduled orders", "error", err)
+		return
+	}
+	for _, id := range due {
+		if err := s.repository.ReleaseScheduled(id); err != nil {
+			config.Logger.Warn("Failed to queue scheduled order", "order_id", id, "error", err)
+			continue
+		}
+		config.Logger.Info("Scheduled order queued", "order_id", id)
+		publishOrder(s.events, s.repository, model.QueueStatusChange, id)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"frappuccino/internal/dal"
+	model "frappuccino/models"
+)
+
+type TaxService interface {
+	Add(rate model.TaxRate) (int, error)
+	GetAll() ([]model.TaxRate, error)
+	GetByID(id int) (model.TaxRate, error)
+	Update(id int, rate model.TaxRate) error
+	Delete(id int) error
+}
+
+type Tax struct {
+	repository dal.TaxRepository
+}
+
+func NewTaxService(repository dal.TaxRepository) *Tax {
+	return &Tax{repository: repository}
+}
+
+var ErrInvalidTaxRate = errors.New("invalid_tax_rate")
+
+func (t *Tax) Add(rate model.TaxRate) (int, error) {
+	if err := validateTaxRate(rate); err != nil {
+		return 0, err
+	}
+	return t.repository.Add(rate)
+}
+
+func (t *Tax) GetAll() ([]model.TaxRate, error) {
+	return t.repository.GetAll()
+}
+
+func (t *Tax) GetByID(id int) (model.TaxRate, error) {
+	return t.repository.GetByID(id)
+}
+
+func (t *Tax) Update(id int, rate model.TaxRate) error {
+	if id <= 0 {
+		return errors.New("id can not be empty or zero")
+	}
+	if err := validateTaxRate(rate); err != nil {
+		return err
+	}
+	return t.repository.Update(id, rate)
+}
+
+func (t *Tax) Delete(id int) error {
+	return t.repository.Delete(id)
+}
+
+func validateTaxRate(rate model.TaxRate) error {
+	if rate.Name == "" {
+		return fmt.Errorf("%w: name can not be empty", ErrInvalidTaxRate)
+	}
+	if rate.Rate <= 0 || rate.Rate > 100 {
+		return fmt.Errorf("%w: rate must be between 0 and 100", ErrInvalidTaxRate)
+	}
+	return nil
+}
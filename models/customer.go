@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type Customer struct {
+	ID          int                    `json:"customer_id"`
+	Name        string                 `json:"name"`
+	Phone       string                 `json:"phone,omitempty"`
+	Email       string                 `json:"email,omitempty"`
+	Preferences map[string]interface{} `json:"preferences"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
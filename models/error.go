@@ -1,6 +1,9 @@
 package models
 
+// Error is the body of a failed request. Code is the machine readable reason
+// of a client error, such as insufficient_inventory.
 type Error struct {
 	Message string `json:"Error"`
+	Code    string `json:"Code,omitempty"`
 	Status  int64  `json:"Status"`
 }
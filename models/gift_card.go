@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Gift card transaction types, matching gift_card_transaction_enum.
+const (
+	GiftCardIssue  = "issue"
+	GiftCardTopUp  = "top_up"
+	GiftCardRedeem = "redeem"
+)
+
+type GiftCard struct {
+	ID           int                   `json:"gift_card_id"`
+	Code         string                `json:"code"`
+	Balance      Money                 `json:"balance"`
+	CreatedAt    time.Time             `json:"created_at"`
+	Transactions []GiftCardTransaction `json:"transactions,omitempty"`
+}
+
+// GiftCardTransaction is one movement of a card balance. Redemptions are
+// negative and point at the payment they paid for.
+type GiftCardTransaction struct {
+	ID        int       `json:"transaction_id"`
+	Type      string    `json:"transaction_type"`
+	Amount    Money     `json:"amount"`
+	PaymentID int       `json:"payment_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GiftCardRequest issues a card with an opening balance or tops one up. Code
+// is generated on issue when left empty.
+type GiftCardRequest struct {
+	Code   string `json:"code"`
+	Amount Money  `json:"amount"`
+}
+
+// GiftCardLiability is the value still owed to gift card holders. It is money
+// taken but not yet earned, so it is kept out of the sales report.
+type GiftCardLiability struct {
+	Cards       int   `json:"cards"`
+	Issued      Money `json:"issued"`
+	Redeemed    Money `json:"redeemed"`
+	Outstanding Money `json:"outstanding"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// IdempotentResponse is the stored response to a request sent with an
+// Idempotency-Key header, replayed when the same request is retried.
+type IdempotentResponse struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Body        []byte
+	CreatedAt   time.Time
+}
@@ -2,10 +2,15 @@ package models
 
 import "time"
 
+// InventoryItem is an ingredient in stock. StockLevel is what is on hand;
+// Reserved is set aside for open orders and Available is what is left for new
+// ones. Only StockLevel can be changed directly.
 type InventoryItem struct {
 	IngredientID *int      `json:"inventory_id"`
 	Name         string    `json:"name"`
 	StockLevel   *float64  `json:"stock_level"`
+	Reserved     float64   `json:"reserved"`
+	Available    float64   `json:"available"`
 	LastUpdated  time.Time `json:"last_updated"`
 	ReorderLevel *float64  `json:"reorder_level"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Loyalty ledger entry types, matching loyalty_entry_enum.
+const (
+	LoyaltyEarn       = "earn"
+	LoyaltyRedeem     = "redeem"
+	LoyaltyReverse    = "reverse"
+	LoyaltyAdjustment = "adjustment"
+)
+
+// LoyaltyEntry is one earn or burn of points. Redemptions are negative.
+type LoyaltyEntry struct {
+	ID        int       `json:"entry_id"`
+	LoyaltyID string    `json:"loyalty_id"`
+	OrderID   int       `json:"order_id,omitempty"`
+	Type      string    `json:"entry_type"`
+	Points    int       `json:"points"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoyaltyBalance struct {
+	LoyaltyID string         `json:"loyalty_id"`
+	Balance   int            `json:"balance"`
+	Entries   []LoyaltyEntry `json:"entries,omitempty"`
+}
@@ -2,11 +2,18 @@ package models
 
 import "time"
 
+// Item sizes, matching item_size_enum.
+const (
+	SizeSmall  = "small"
+	SizeMedium = "medium"
+	SizeLarge  = "large"
+)
+
 type MenuItem struct {
 	ID          int      `json:"id"`
 	Name        string   `json:"name"`
 	Description string   `json:"description"`
-	Price       float64  `json:"price"`
+	Price       Money    `json:"price"`
 	Tags        []string `json:"tags"`
 }
 
@@ -20,21 +27,44 @@ type MenuItemIngredient struct {
 type MenuPriceHistory struct {
 	ID         int       `json:"id"`
 	MenuItemID int       `json:"menu_item_id"`
-	OldPrice   float64   `json:"old_price"`
-	NewPrice   float64   `json:"new_price"`
+	OldPrice   Money     `json:"old_price"`
+	NewPrice   Money     `json:"new_price"`
 	ChangedAt  time.Time `json:"changed_at"`
 }
 
 type MenuResponse struct {
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
-	Price       float64              `json:"price"`
+	Price       Money                `json:"price"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
 }
 
+// MenuRequest adds or updates a menu item. On update, Sizes and Modifiers that
+// are left out keep the current ones, while an empty list removes them all.
 type MenuRequest struct {
 	Menu            MenuItem        `json:"menu_item"`
 	MenuIngredients []MenuInventory `json:"ingredients"`
+	Sizes           []MenuItemSize  `json:"sizes,omitempty"`
+	Modifiers       []Modifier      `json:"modifiers,omitempty"`
+}
+
+// MenuItemSize is the price of a menu item in one size. The recipe quantities
+// are multiplied by IngredientMultiplier. Without an entry the medium size is
+// sold at the menu item price with the plain recipe.
+type MenuItemSize struct {
+	Size                 string  `json:"size"`
+	Price                Money   `json:"price"`
+	IngredientMultiplier float64 `json:"ingredient_multiplier"`
+}
+
+// Modifier is an option that can be chosen for a menu item. PriceDelta is added
+// to the item price and the ingredient quantities are added to its recipe;
+// a negative quantity removes that amount of an ingredient.
+type Modifier struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	PriceDelta  Money           `json:"price_delta"`
+	Ingredients []MenuInventory `json:"ingredients"`
 }
 
 type MenuInventory struct {
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of money in minor units (cents), matching the
+// DECIMAL(10,2) columns of the database.
+//
+// Rounding policy: whenever an amount has to be brought to whole cents, be it
+// a parsed value with more than two decimals, a percentage or a share of
+// another amount, it is rounded half away from zero, so 0.125 becomes 0.13 and
+// -0.125 becomes -0.13. Sums and multiples by whole quantities are exact.
+type Money int64
+
+// MoneyFromFloat converts a float amount, e.g. a DECIMAL(10,2) value read as
+// float64, to Money.
+func MoneyFromFloat(amount float64) Money {
+	return Money(math.Round(amount * 100))
+}
+
+// ParseMoney parses a decimal amount such as "12.5", "-3" or "0.125" without
+// going through floating point.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("invalid money amount %q", s)
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid money amount %q", s)
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, fmt.Errorf("invalid money amount %q", s)
+			}
+		}
+	}
+
+	var units int64
+	if whole != "" {
+		var err error
+		if units, err = strconv.ParseInt(whole, 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid money amount %q: %w", s, err)
+		}
+	}
+
+	fraction += "000"
+	cents, _ := strconv.ParseInt(fraction[:2], 10, 64)
+	if fraction[2] >= '5' {
+		cents++
+	}
+
+	amount := Money(units*100 + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// Mul returns the amount multiplied by a whole quantity.
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Percent returns rate percent of the amount. The rate is taken with two
+// decimals, as stored in DECIMAL(5,2) columns.
+func (m Money) Percent(rate float64) Money {
+	return Money(divRound(int64(m)*basisPoints(rate), 10000))
+}
+
+// IncludedPercent returns the part of the amount that is rate percent tax
+// already included in it.
+func (m Money) IncludedPercent(rate float64) Money {
+	bp := basisPoints(rate)
+	return Money(divRound(int64(m)*bp, 10000+bp))
+}
+
+// Share returns the amount scaled by part/whole, e.g. the share of a discount
+// that falls on one order line. A zero whole returns the amount unchanged.
+func (m Money) Share(part, whole Money) Money {
+	if whole == 0 {
+		return m
+	}
+	return Money(divRound(int64(m)*int64(part), int64(whole)))
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		text = string(data)
+	}
+
+	amount, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Scan reads a DECIMAL column.
+func (m *Money) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		amount, err := ParseMoney(string(value))
+		*m = amount
+		return err
+	case string:
+		amount, err := ParseMoney(value)
+		*m = amount
+		return err
+	case int64:
+		*m = Money(value * 100)
+		return nil
+	case float64:
+		*m = MoneyFromFloat(value)
+		return nil
+	default:
+		return fmt.Errorf("can not scan %T into Money", src)
+	}
+}
+
+// Value writes the amount as a decimal string so the database never sees a
+// float.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func basisPoints(rate float64) int64 {
+	return int64(math.Round(rate * 100))
+}
+
+// divRound divides a by b rounding half away from zero.
+func divRound(a, b int64) int64 {
+	if b < 0 {
+		a, b = -a, -b
+	}
+	if a < 0 {
+		return -((-a + b/2) / b)
+	}
+	return (a + b/2) / b
+}
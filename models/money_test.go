@@ -0,0 +1,271 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"12.5", 1250},
+		{"12.50", 1250},
+		{"-3", -300},
+		{"+2.10", 210},
+		{" 7 ", 700},
+		{".5", 50},
+		{"5.", 500},
+		{"0", 0},
+		{"-0", 0},
+
+		// More than two decimals round half away from zero on the third.
+		{"0.004", 0},
+		{"0.005", 1},
+		{"0.014", 1},
+		{"0.015", 2},
+		{"0.125", 13},
+		{"0.0149", 1},
+		{"0.0150", 2},
+		{"1.995", 200},
+		{"9.999", 1000},
+		{"-0.005", -1},
+		{"-0.015", -2},
+		{"-0.125", -13},
+		{"-1.994", -199},
+	}
+	for _, test := range tests {
+		got, err := ParseMoney(test.in)
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, in := range []string{"", " ", "-", "+", ".", "abc", "1.2.3", "1e3", "--1", "1,5", "0x10", "12.5a", "99999999999999999999"} {
+		if got, err := ParseMoney(in); err == nil {
+			t.Errorf("ParseMoney(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		a, b, want int64
+	}{
+		{0, 7, 0},
+		{1, 3, 0},
+		{4, 3, 1},
+		{5, 2, 3},
+		{3, 2, 2},
+		{7, 2, 4},
+		{-5, 2, -3},
+		{5, -2, -3},
+		{-5, -2, 3},
+		{-4, 3, -1},
+		{10, 5, 2},
+	}
+	for _, test := range tests {
+		if got := divRound(test.a, test.b); got != test.want {
+			t.Errorf("divRound(%d, %d) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount Money
+		rate   float64
+		want   Money
+	}{
+		{1000, 12.5, 125},
+		{1000, 0, 0},
+		{1000, 100, 1000},
+		{199, 10, 20},
+		{5, 10, 1},
+		{4, 10, 0},
+		{-5, 10, -1},
+		{-199, 10, -20},
+		{333, 33.33, 111},
+	}
+	for _, test := range tests {
+		if got := test.amount.Percent(test.rate); got != test.want {
+			t.Errorf("%s.Percent(%v) = %s, want %s", test.amount, test.rate, got, test.want)
+		}
+	}
+}
+
+func TestIncludedPercent(t *testing.T) {
+	tests := []struct {
+		amount Money
+		rate   float64
+		want   Money
+	}{
+		{1100, 10, 100},
+		{1000, 20, 167},
+		{-1000, 20, -167},
+		{1000, 0, 0},
+		{105, 5, 5},
+		{21, 5, 1},
+	}
+	for _, test := range tests {
+		if got := test.amount.IncludedPercent(test.rate); got != test.want {
+			t.Errorf("%s.IncludedPercent(%v) = %s, want %s", test.amount, test.rate, got, test.want)
+		}
+	}
+}
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		amount      Money
+		part, whole Money
+		want        Money
+	}{
+		{100, 1, 3, 33},
+		{200, 1, 3, 67},
+		{100, 1, 2, 50},
+		{5, 1, 2, 3},
+		{-5, 1, 2, -3},
+		{100, 0, 3, 0},
+		{100, 3, 3, 100},
+		{100, 5, 0, 100},
+	}
+	for _, test := range tests {
+		if got := test.amount.Share(test.part, test.whole); got != test.want {
+			t.Errorf("%s.Share(%s, %s) = %s, want %s", test.amount, test.part, test.whole, got, test.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{1250, "12.50"},
+		{-123456, "-1234.56"},
+	}
+	for _, test := range tests {
+		if got := test.amount.String(); got != test.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(test.amount), got, test.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	type payload struct {
+		Amount Money `json:"amount"`
+	}
+
+	data, err := json.Marshal(payload{Amount: -1250})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"amount":-12.50}` {
+		t.Errorf("Marshal = %s, want {\"amount\":-12.50}", data)
+	}
+
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{`{"amount":12.5}`, 1250},
+		{`{"amount":"12.5"}`, 1250},
+		{`{"amount":0.125}`, 13},
+		{`{"amount":"-0.005"}`, -1},
+		{`{"amount":7}`, 700},
+		{`{"amount":null}`, 0},
+		{`{}`, 0},
+	}
+	for _, test := range tests {
+		var got payload
+		if err := json.Unmarshal([]byte(test.in), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", test.in, err)
+			continue
+		}
+		if got.Amount != test.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", test.in, got.Amount, test.want)
+		}
+	}
+
+	for _, in := range []string{`{"amount":"abc"}`, `{"amount":true}`, `{"amount":"1.2.3"}`, `{"amount":[1]}`} {
+		var got payload
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Errorf("Unmarshal(%s) = %s, want an error", in, got.Amount)
+		}
+	}
+
+	for _, amount := range []Money{0, 1, -1, 99, 1250, -123456} {
+		data, err := json.Marshal(payload{Amount: amount})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got payload
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Amount != amount {
+			t.Errorf("JSON round trip of %s gave %s", amount, got.Amount)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Money
+	}{
+		{[]byte("12.34"), 1234},
+		{"0.10", 10},
+		{"-0.05", -5},
+		{[]byte("0.125"), 13},
+		{int64(3), 300},
+		{float64(2.5), 250},
+		{nil, 0},
+	}
+	for _, test := range tests {
+		got := Money(42)
+		if err := got.Scan(test.src); err != nil {
+			t.Errorf("Scan(%#v): %v", test.src, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Scan(%#v) = %s, want %s", test.src, got, test.want)
+		}
+	}
+
+	for _, src := range []interface{}{true, []byte("abc"), "1,5"} {
+		var got Money
+		if err := got.Scan(src); err == nil {
+			t.Errorf("Scan(%#v) = %s, want an error", src, got)
+		}
+	}
+}
+
+func TestMoneySQLRoundTrip(t *testing.T) {
+	for _, amount := range []Money{0, 1, -1, 99, 1250, -123456} {
+		value, err := amount.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := value.(string); !ok {
+			t.Errorf("Value of %s is %T, want a decimal string", amount, value)
+		}
+		var got Money
+		if err := got.Scan(value); err != nil {
+			t.Fatal(err)
+		}
+		if got != amount {
+			t.Errorf("SQL round trip of %s gave %s", amount, got)
+		}
+	}
+}
@@ -2,12 +2,23 @@ package models
 
 import "time"
 
+// Order statuses, matching order_status_enum.
+const (
+	StatusScheduled = "scheduled"
+	StatusPending   = "pending"
+	StatusPreparing = "preparing"
+	StatusReady     = "ready"
+	StatusPickedUp  = "picked_up"
+	StatusClosed    = "closed"
+	StatusCancelled = "cancelled"
+)
+
 type Order struct {
 	OrderID            int                    `json:"order_id"`
 	CustomerName       string                 `json:"customer_name"`
 	OrderDate          time.Time              `json:"order_date"`
 	Status             string                 `json:"status"`
-	TotalPrice         float64                `json:"total_amount"`
+	TotalPrice         Money                  `json:"total_amount"`
 	SpecialInstruction map[string]interface{} `json:"special_instruction"`
 }
 
@@ -16,25 +27,72 @@ type OrderItem struct {
 	MenuItemID       int                    `json:"menu_item_id"`
 	OrderID          int                    `json:"order_id"`
 	Customizations   map[string]interface{} `json:"customizations"`
-	PriceAtOrderTime float64                `json:"price_at_order_time"`
+	PriceAtOrderTime Money                  `json:"price_at_order_time"`
 	Quantity         int                    `json:"quantity"`
 }
 
 type OrderStatusHistory struct {
-	ID       int    `json:"id"`
-	OrderID  int    `json:"order_id"`
-	Status   string `json:"status"`
-	ChangeAt string `json:"change_at"`
+	ID          int       `json:"id"`
+	OrderID     int       `json:"order_id"`
+	Status      string    `json:"status"`
+	ChangedAt   time.Time `json:"changed_at"`
+	TimeInState float64   `json:"time_in_state_seconds"`
+}
+
+type OrderStatusRequest struct {
+	Status string `json:"status"`
 }
 
 type OrderRequest struct {
-	CustomerName string             `json:"customer_name"`
-	Orders       []OrderItemRequest `json:"orders"`
+	CustomerID          int                    `json:"customer_id"`
+	CustomerName        string                 `json:"customer_name"`
+	SpecialInstructions map[string]interface{} `json:"special_instructions"`
+	PromoCode           string                 `json:"promo_code"`
+	Tip                 Money                  `json:"tip"`
+	LoyaltyID           string                 `json:"loyalty_id"`
+	RedeemPoints        int                    `json:"redeem_points"`
+	PickupAt            *time.Time             `json:"pickup_at"`
+	Orders              []OrderItemRequest     `json:"orders"`
 }
 
 type OrderItemRequest struct {
-	MenuItemID string `json:"product_id"`
-	Quantity   int    `json:"quantity"`
+	MenuItemID     string                 `json:"product_id"`
+	Quantity       int                    `json:"quantity"`
+	Size           string                 `json:"size"`
+	Modifiers      []string               `json:"modifiers"`
+	Customizations map[string]interface{} `json:"customizations"`
+}
+
+// PlacedOrder describes an order that has just been created. A pre-order is
+// placed as scheduled and its ingredients are only reserved.
+type PlacedOrder struct {
+	OrderID          int               `json:"order_id"`
+	Status           string            `json:"status"`
+	PickupAt         *time.Time        `json:"pickup_at,omitempty"`
+	Subtotal         Money             `json:"subtotal"`
+	Discount         Money             `json:"discount_amount"`
+	Tax              Money             `json:"tax_amount"`
+	Tip              Money             `json:"tip_amount"`
+	Total            Money             `json:"total_amount"`
+	Discounts        []OrderDiscount   `json:"discounts,omitempty"`
+	Taxes            []OrderTax        `json:"taxes,omitempty"`
+	PointsRedeemed   int               `json:"points_redeemed,omitempty"`
+	UnavailableItems []UnavailableItem `json:"unavailable_items,omitempty"`
+	InventoryUpdates []InventoryUpdate `json:"inventory_updates"`
+}
+
+// Reasons an item of an earlier order can not be ordered again.
+const (
+	UnavailableMenuItem = "menu_item_removed"
+	UnavailableSize     = "size_unavailable"
+	UnavailableModifier = "modifier_unavailable"
+)
+
+// UnavailableItem is an item left out of a reorder and why.
+type UnavailableItem struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
 }
 
 type InventoryUpdates struct {
@@ -45,40 +103,69 @@ type InventoryUpdates struct {
 }
 
 type OrderResponse struct {
-	OrderID      int              `json:"order_id"`
-	CustomerName string           `json:"customer_name"`
-	Items        []OrderItemShort `json:"items"`
-	Status       string           `json:"status"`
-	CreatedAt    time.Time        `json:"created_at"`
+	OrderID             int                    `json:"order_id"`
+	CustomerID          int                    `json:"customer_id,omitempty"`
+	CustomerName        string                 `json:"customer_name"`
+	LoyaltyID           string                 `json:"loyalty_id,omitempty"`
+	Items               []OrderItemShort       `json:"items"`
+	Status              string                 `json:"status"`
+	SpecialInstructions map[string]interface{} `json:"special_instructions,omitempty"`
+	Subtotal            Money                  `json:"subtotal"`
+	Discount            Money                  `json:"discount_amount"`
+	Tax                 Money                  `json:"tax_amount"`
+	Tip                 Money                  `json:"tip_amount"`
+	Total               Money                  `json:"total_amount"`
+	Discounts           []OrderDiscount        `json:"discounts,omitempty"`
+	Taxes               []OrderTax             `json:"taxes,omitempty"`
+	PickupAt            *time.Time             `json:"pickup_at,omitempty"`
+	FulfillmentIssue    string                 `json:"fulfillment_issue,omitempty"`
+	CreatedAt           time.Time              `json:"created_at"`
 }
 
 type OrderItemShort struct {
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
+	OrderItemID    int                    `json:"order_item_id,omitempty"`
+	ProductID      string                 `json:"product_id"`
+	Quantity       int                    `json:"quantity"`
+	Size           string                 `json:"size"`
+	Modifiers      []string               `json:"modifiers,omitempty"`
+	Customizations map[string]interface{} `json:"customizations,omitempty"`
 }
 
 type NumberOfOrderedItemsResponse map[string]int
 
+// NumberOfOrderedItemsBySizeResponse maps item names to quantities per size.
+type NumberOfOrderedItemsBySizeResponse map[string]map[string]int
+
 type BatchOrderRequest struct {
 	Orders []OrderRequestBatch `json:"orders"`
 }
 
 type OrderRequestBatch struct {
-	CustomerName string                  `json:"customer_name"`
-	Items        []OrderItemRequestBatch `json:"items"`
+	CustomerID          int                     `json:"customer_id"`
+	CustomerName        string                  `json:"customer_name"`
+	SpecialInstructions map[string]interface{}  `json:"special_instructions"`
+	PromoCode           string                  `json:"promo_code"`
+	Tip                 Money                   `json:"tip"`
+	LoyaltyID           string                  `json:"loyalty_id"`
+	RedeemPoints        int                     `json:"redeem_points"`
+	Items               []OrderItemRequestBatch `json:"items"`
 }
 
 type OrderItemRequestBatch struct {
-	MenuItemName string `json:"product_name"`
-	Quantity     int    `json:"quantity"`
+	MenuItemName   string                 `json:"product_name"`
+	Quantity       int                    `json:"quantity"`
+	Size           string                 `json:"size"`
+	Modifiers      []string               `json:"modifiers"`
+	Customizations map[string]interface{} `json:"customizations"`
 }
 
 type ProcessedOrder struct {
-	OrderID      int     `json:"order_id,omitempty"`
-	CustomerName string  `json:"customer_name"`
-	Status       string  `json:"status"`
-	Total        float64 `json:"total,omitempty"`
-	Reason       string  `json:"reason,omitempty"`
+	OrderID      int    `json:"order_id,omitempty"`
+	CustomerName string `json:"customer_name"`
+	Status       string `json:"status"`
+	Total        Money  `json:"total,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	Detail       string `json:"detail,omitempty"`
 }
 
 type InventoryUpdate struct {
@@ -92,11 +179,42 @@ type BatchSummary struct {
 	TotalOrders      int               `json:"total_orders"`
 	Accepted         int               `json:"accepted"`
 	Rejected         int               `json:"rejected"`
-	TotalRevenue     float64           `json:"total_revenue"`
+	TotalRevenue     Money             `json:"total_revenue"`
 	InventoryUpdates []InventoryUpdate `json:"inventory_updates"`
 }
 
+// OrderFilter narrows down and orders the list of orders. Empty fields do not
+// filter; dates are YYYY-MM-DD days in Location and both ends of the range are
+// included.
+type OrderFilter struct {
+	Statuses     []string
+	CustomerName string
+	StartDate    string
+	EndDate      string
+	MinTotal     *Money
+	MaxTotal     *Money
+	MenuItem     string
+	SortBy       string // date, total or id
+	Order        string // asc or desc
+	Page         int
+	PageSize     int
+	Location     *time.Location
+}
+
+// OrderPage is one page of the filtered list of orders.
+type OrderPage struct {
+	CurrentPage int             `json:"currentPage"`
+	HasNextPage bool            `json:"hasNextPage"`
+	PageSize    int             `json:"pageSize"`
+	TotalPages  int             `json:"totalPages"`
+	TotalOrders int             `json:"totalOrders"`
+	Data        []OrderResponse `json:"data"`
+}
+
+// BatchOrderResponse reports on a batch. In a dry run nothing was kept:
+// accepted orders have no id and the inventory updates are projections.
 type BatchOrderResponse struct {
+	DryRun          bool             `json:"dry_run,omitempty"`
 	ProcessedOrders []ProcessedOrder `json:"processed_orders"`
 	Summary         BatchSummary     `json:"summary"`
 }
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Payment methods, matching payment_method_enum.
+const (
+	PaymentCash     = "cash"
+	PaymentCard     = "card"
+	PaymentMobile   = "mobile"
+	PaymentGiftCard = "gift_card"
+)
+
+type Payment struct {
+	PaymentID    int       `json:"payment_id"`
+	OrderID      int       `json:"order_id"`
+	Method       string    `json:"method"`
+	GiftCardCode string    `json:"gift_card_code,omitempty"`
+	Amount       Money     `json:"amount"`
+	Tendered     Money     `json:"tendered"`
+	ChangeDue    Money     `json:"change_due"`
+	PaidAt       time.Time `json:"paid_at"`
+}
+
+type PaymentRequest struct {
+	Payments []TenderRequest `json:"payments"`
+}
+
+// TenderRequest is one tender paid against an order. Amount defaults to the
+// outstanding balance, or to what is left on the card for a gift card;
+// Tendered is only used for cash, where the change due is Tendered minus
+// Amount. GiftCardCode names the card a gift card tender is taken from.
+type TenderRequest struct {
+	Method       string `json:"method"`
+	GiftCardCode string `json:"gift_card_code"`
+	Amount       Money  `json:"amount"`
+	Tendered     Money  `json:"tendered"`
+}
+
+// PaymentSummary shows what has been paid for an order. ChangeDue is the cash
+// to hand back for the tenders recorded by the current request.
+type PaymentSummary struct {
+	OrderID   int       `json:"order_id"`
+	Total     Money     `json:"total_amount"`
+	Paid      Money     `json:"paid"`
+	Balance   Money     `json:"balance"`
+	ChangeDue Money     `json:"change_due,omitempty"`
+	Payments  []Payment `json:"payments"`
+}
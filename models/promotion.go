@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Discount types and scopes, matching discount_type_enum and
+// promotion_scope_enum.
+const (
+	DiscountPercentage = "percentage"
+	DiscountFixed      = "fixed"
+
+	ScopeOrder = "order"
+	ScopeTag   = "tag"
+	ScopeItem  = "item"
+)
+
+// Promotion is a discount rule. Promotions without a code apply to every
+// order automatically; the others only when their code is entered. Tag and
+// item scoped promotions only discount the matching order lines. Percentage
+// promotions set Percent and fixed ones Amount.
+type Promotion struct {
+	ID           int        `json:"promotion_id"`
+	Name         string     `json:"name"`
+	Code         string     `json:"code,omitempty"`
+	DiscountType string     `json:"discount_type"`
+	Percent      float64    `json:"percent,omitempty"`
+	Amount       Money      `json:"amount,omitempty"`
+	Scope        string     `json:"scope"`
+	Tag          string     `json:"tag,omitempty"`
+	MenuItemID   int        `json:"menu_item_id,omitempty"`
+	UsageLimit   int        `json:"usage_limit,omitempty"`
+	TimesUsed    int        `json:"times_used"`
+	ValidFrom    *time.Time `json:"valid_from,omitempty"`
+	ValidUntil   *time.Time `json:"valid_until,omitempty"`
+	Active       bool       `json:"active"`
+}
+
+// OrderDiscount is a discount applied to an order.
+type OrderDiscount struct {
+	PromotionID int    `json:"promotion_id,omitempty"`
+	Name        string `json:"name"`
+	Code        string `json:"code,omitempty"`
+	Amount      Money  `json:"amount"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Kitchen queue event types.
+const (
+	QueueOrderQueued  = "order_queued" // sent for every open order when a client connects
+	QueueOrderCreated = "order_created"
+	QueueOrderUpdated = "order_updated"
+	QueueStatusChange = "status_changed"
+	QueueOrderDeleted = "order_deleted"
+)
+
+// QueueEvent is one update of the kitchen queue. ElapsedSeconds is the time
+// since the order was placed when the event was sent. A deleted order only
+// carries its id.
+type QueueEvent struct {
+	Type                string                 `json:"type"`
+	OrderID             int                    `json:"order_id"`
+	CustomerName        string                 `json:"customer_name,omitempty"`
+	Status              string                 `json:"status,omitempty"`
+	Items               []OrderItemShort       `json:"items,omitempty"`
+	SpecialInstructions map[string]interface{} `json:"special_instructions,omitempty"`
+	PickupAt            *time.Time             `json:"pickup_at,omitempty"`
+	CreatedAt           *time.Time             `json:"created_at,omitempty"`
+	ElapsedSeconds      int64                  `json:"elapsed_seconds,omitempty"`
+}
+
+// NewQueueEvent builds an event of the given type from the current state of an
+// order.
+func NewQueueEvent(eventType string, order OrderResponse) QueueEvent {
+	createdAt := order.CreatedAt
+	return QueueEvent{
+		Type:                eventType,
+		OrderID:             order.OrderID,
+		CustomerName:        order.CustomerName,
+		Status:              order.Status,
+		Items:               order.Items,
+		SpecialInstructions: order.SpecialInstructions,
+		PickupAt:            order.PickupAt,
+		CreatedAt:           &createdAt,
+		ElapsedSeconds:      int64(time.Since(order.CreatedAt).Seconds()),
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Refund reasons, matching refund_reason_enum.
+const (
+	RefundCustomerComplaint = "customer_complaint"
+	RefundWrongItem         = "wrong_item"
+	RefundQualityIssue      = "quality_issue"
+	RefundNotMade           = "not_made"
+	RefundOther             = "other"
+)
+
+type Refund struct {
+	RefundID  int          `json:"refund_id"`
+	OrderID   int          `json:"order_id"`
+	Reason    string       `json:"reason"`
+	Amount    Money        `json:"amount"`
+	Restocked bool         `json:"restocked"`
+	Note      string       `json:"note,omitempty"`
+	Items     []RefundItem `json:"items"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type RefundItem struct {
+	OrderItemID int   `json:"order_item_id"`
+	Quantity    int   `json:"quantity"`
+	Amount      Money `json:"amount"`
+}
+
+// RefundRequest refunds the listed order items, or everything not yet
+// refunded when Items is empty. Restock returns the ingredients of the
+// refunded items to inventory and is meant for items that were never made.
+type RefundRequest struct {
+	Reason  string              `json:"reason"`
+	Note    string              `json:"note"`
+	Restock bool                `json:"restock"`
+	Items   []RefundItemRequest `json:"items"`
+}
+
+type RefundItemRequest struct {
+	OrderItemID int `json:"order_item_id"`
+	Quantity    int `json:"quantity"`
+}
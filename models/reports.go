@@ -1,14 +1,35 @@
 package models
 
+// TotalSalesStruct breaks down the revenue of closed orders. NetSales is gross
+// sales less discounts; tax, tips and refunds are reported on their own lines
+// and TotalSales is what was charged. NetOfRefunds is what was charged less
+// what was refunded from it, i.e. the money the shop kept.
 type TotalSalesStruct struct {
-	TotalSales float64 `json:"total_sales"`
+	GrossSales      Money            `json:"gross_sales"`
+	Discounts       Money            `json:"discounts"`
+	ExclusiveTax    Money            `json:"exclusive_tax"`
+	InclusiveTax    Money            `json:"inclusive_tax"`
+	Tips            Money            `json:"tips"`
+	TotalSales      Money            `json:"total_sales"`
+	Refunds         Money            `json:"refunds"`
+	NetSales        Money            `json:"net_sales"`
+	NetOfRefunds    Money            `json:"net_of_refunds"`
+	ByTaxRate       map[string]Money `json:"by_tax_rate"`
+	ByPaymentMethod map[string]Money `json:"by_payment_method,omitempty"`
 }
 
 type PopularItem struct {
 	Name     string `json:"name"`
+	Size     string `json:"size,omitempty"`
 	Quantity int    `json:"quantity"`
 }
 
+type StatusDuration struct {
+	Status         string  `json:"status"`
+	Orders         int     `json:"orders"`
+	AverageSeconds float64 `json:"average_seconds"`
+}
+
 type SearchResponse struct {
 	MenuItems    []MenuItemResult `json:"menu_items,omitempty"`
 	Orders       []OrderResult    `json:"orders,omitempty"`
@@ -19,7 +40,7 @@ type MenuItemResult struct {
 	ID          string  `json:"ID"`
 	Name        string  `json:"Name"`
 	Description string  `json:"Description"`
-	Price       float64 `json:"Price"`
+	Price       Money   `json:"Price"`
 	Relevance   float64 `json:"Relevance"`
 }
 
@@ -27,7 +48,7 @@ type OrderResult struct {
 	ID            string   `json:"ID"`
 	Customer_name string   `json:"Customer_name"`
 	Items         []string `json:"Items"`
-	Total         float64  `json:"Total"`
+	Total         Money    `json:"Total"`
 	Relevance     float64  `json:"Relevance"`
 }
 
@@ -0,0 +1,22 @@
+package models
+
+// TaxRate is a tax charged on order lines. A rate without a tag applies to
+// every line. Inclusive rates are already part of the menu prices, so they are
+// reported but not added to the order total.
+type TaxRate struct {
+	ID        int     `json:"tax_rate_id"`
+	Name      string  `json:"name"`
+	Rate      float64 `json:"rate"`
+	Tag       string  `json:"tag,omitempty"`
+	Inclusive bool    `json:"inclusive"`
+	Active    bool    `json:"active"`
+}
+
+// OrderTax is the tax an order owes under one rate.
+type OrderTax struct {
+	TaxRateID int     `json:"tax_rate_id,omitempty"`
+	Name      string  `json:"name"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+	Amount    Money   `json:"amount"`
+}